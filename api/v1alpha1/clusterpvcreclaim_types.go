@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReclaimNamespace records the namespace a PVCReclaim lived in so it can be re-created
+type ReclaimNamespace struct {
+	// Name is the name of the namespace
+	Name string `json:"name"`
+	// Labels are the labels of the namespace
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are the annotations of the namespace
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// PVCReclaimTemplate records a PVCReclaim so it can be re-created in its namespace
+type PVCReclaimTemplate struct {
+	// Name is the name of the PVCReclaim and of the deleted PersistentVolumeClaim
+	Name string `json:"name"`
+	// Labels are the labels of the PVCReclaim
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are the annotations of the PVCReclaim
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Spec is the spec of the PVCReclaim
+	Spec PVCReclaimSpec `json:"spec"`
+	// ClaimUID is the UID of the deleted PersistentVolumeClaim the PersistentVolume is reserved for
+	// +optional
+	ClaimUID types.UID `json:"claimUID,omitempty"`
+	// LegalHold is the state of the legal hold on the PVCReclaim
+	// +optional
+	LegalHold *LegalHoldStatus `json:"legalHold,omitempty"`
+}
+
+// ClusterPVCReclaimSpec defines the desired state of ClusterPVCReclaim
+type ClusterPVCReclaimSpec struct {
+	// Namespace is the namespace the PVCReclaim lived in before it was deleted
+	Namespace ReclaimNamespace `json:"namespace"`
+	// PVCReclaim is the PVCReclaim deleted along with its namespace
+	PVCReclaim PVCReclaimTemplate `json:"pvcReclaim"`
+	// Restore indicates whether the namespace and PVCReclaim should be re-created and the deleted PVC restored
+	Restore bool `json:"restore"`
+}
+
+// ClusterPVCReclaimStatus defines the observed state of ClusterPVCReclaim
+type ClusterPVCReclaimStatus struct {
+	// RecoverStatus is the status of the current cluster PVC reclaim resource
+	RecoverStatus PVCReclaimRecoverStatus `json:"recoverStatus,omitempty"`
+	// Reason provides messages indicating reason related to recovery failure
+	Reason string `json:"reason,omitempty"`
+	// Message is used to provide additional information regarding the state of the cluster reclaim resource
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterPVCReclaim keeps a PVCReclaim whose namespace was deleted so it can be restored
+type ClusterPVCReclaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterPVCReclaimSpec   `json:"spec,omitempty"`
+	Status ClusterPVCReclaimStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterPVCReclaimList contains a list of ClusterPVCReclaim
+type ClusterPVCReclaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPVCReclaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterPVCReclaim{}, &ClusterPVCReclaimList{})
+}
@@ -18,7 +18,9 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type PVCReclaimRecoverStatus string
@@ -28,16 +30,387 @@ const (
 	RecoveryInProgress PVCReclaimRecoverStatus = "RecoveryInProgress"
 	RecoveryFailed     PVCReclaimRecoverStatus = "RecoveryFailed"
 	RecoverySuccess    PVCReclaimRecoverStatus = "RecoverySuccess"
+	ReleasedToPool     PVCReclaimRecoverStatus = "ReleasedToPool"
+	PurgeInProgress    PVCReclaimRecoverStatus = "PurgeInProgress"
 )
 
+// Condition types reported in PVCReclaimStatus.Conditions
+const (
+	// ConditionClaimRefDrift indicates the claimRef of the PersistentVolume was changed away from the
+	// original PersistentVolumeClaim and has been re-asserted by the controller
+	ConditionClaimRefDrift = "ClaimRefDrift"
+	// ConditionDeletionBlocked indicates a deletion of the reclaim is held back until it is confirmed
+	ConditionDeletionBlocked = "DeletionBlocked"
+	// ConditionPersistentVolumeMissing indicates the PersistentVolume object was deleted while its backing volume is retained
+	ConditionPersistentVolumeMissing = "PersistentVolumeMissing"
+	// ConditionAdopted indicates the reclaim was rebuilt by the controller rather than created from a Bound PVC
+	ConditionAdopted = "Adopted"
+	// ConditionStale indicates the PersistentVolume was replaced by a different one under the same name
+	ConditionStale = "Stale"
+	// ConditionSuperseded indicates the PersistentVolume has been bound to a claim other than the deleted one
+	ConditionSuperseded = "Superseded"
+	// ConditionDuplicate indicates another reclaim references the same PersistentVolume
+	ConditionDuplicate = "Duplicate"
+	// ConditionPreflightPassed indicates whether the preflight checks of the last restore passed
+	ConditionPreflightPassed = "PreflightPassed"
+	// ConditionLegalHoldRejected indicates a legal hold was not placed because the reclaim was already being deleted
+	ConditionLegalHoldRejected = "LegalHoldRejected"
+)
+
+// PVCReclaimRelease describes how the PersistentVolume is handed back to the pool of Available volumes
+type PVCReclaimRelease struct {
+	// Labels are added to the PersistentVolume for PersistentVolumeClaims with a selector to match, a
+	// PersistentVolumeClaim without a selector can still bind to it
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// StorageClassName moves the PersistentVolume into a dedicated StorageClass for the pool, so only
+	// PersistentVolumeClaims requesting that class bind to it
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+}
+
+// LegalHold places the reclaim and its PersistentVolume under a legal hold
+type LegalHold struct {
+	// Enabled indicates whether the hold is in place, setting it back to false lifts the hold
+	Enabled bool `json:"enabled"`
+	// RequestedBy identifies who placed or lifted the hold
+	RequestedBy string `json:"requestedBy"`
+	// Reason describes why the hold was placed or lifted
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// RestoreOverrides change the restored PersistentVolumeClaim from the recorded spec
+type RestoreOverrides struct {
+	// StorageRequest replaces the storage request, a request above the PersistentVolume capacity
+	// expands the volume and requires a StorageClass which allows volume expansion
+	// +optional
+	StorageRequest *resource.Quantity `json:"storageRequest,omitempty"`
+	// Labels are added to the labels of the restored PersistentVolumeClaim
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the annotations of the restored PersistentVolumeClaim
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// VolumeAttributesClassName replaces the VolumeAttributesClass, which must belong to the CSI driver of the PersistentVolume
+	// +optional
+	VolumeAttributesClassName *string `json:"volumeAttributesClassName,omitempty"`
+}
+
+// Migration restores the PersistentVolumeClaim into another StorageClass by copying the data off the PersistentVolume
+type Migration struct {
+	// StorageClassName is the StorageClass the restored PersistentVolumeClaim is provisioned in
+	StorageClassName string `json:"storageClassName"`
+	// StagingNamespace is where the data is copied, the namespace configured on the controller by default
+	// +optional
+	StagingNamespace string `json:"stagingNamespace,omitempty"`
+}
+
+// Archive uploads the contents of the released PersistentVolume to S3-compatible object storage
+type Archive struct {
+	// Endpoint is the URL of the S3-compatible endpoint
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+	// Bucket is the bucket the archive is uploaded to
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the object key of the archive
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// CredentialsSecretName is the Secret in the namespace of the reclaim holding the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the bucket
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
 // PVCReclaimSpec defines the desired state of PVCReclaim
+// +kubebuilder:validation:XValidation:rule="!(self.restore && has(self.releaseToPool))",message="restore and releaseToPool are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!(has(self.purge) && self.purge && (self.restore || has(self.releaseToPool)))",message="purge cannot be combined with restore or releaseToPool"
+// +kubebuilder:validation:XValidation:rule="!(has(self.preview) && self.preview && (self.restore || has(self.releaseToPool) || (has(self.purge) && self.purge)))",message="preview cannot be combined with restore, releaseToPool or purge"
 type PVCReclaimSpec struct {
-	// PersistentVolumeRef is the reference to the PersistentVolume resource bound by the deleted PersistentVolumeClaim
+	// PersistentVolumeRef is the reference to the PersistentVolume resource bound by the deleted PersistentVolumeClaim,
+	// its UID is checked against the PersistentVolume before a restore
 	PersistentVolumeRef *corev1.ObjectReference `json:"persistentVolumeRef"`
-	// PersistentVolumeClaimSpec is the spec for PersistentVolumeClaim resource bound to the PersistentVolume
+	// PersistentVolumeClaimSpec is the spec for PersistentVolumeClaim resource bound to the PersistentVolume,
+	// it is immutable so changes to the restored PersistentVolumeClaim go through RestoreOverrides
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="persistentVolumeClaimSpec is immutable, use restoreOverrides"
 	PersistentVolumeClaimSpec corev1.PersistentVolumeClaimSpec `json:"persistentVolumeClaimSpec"`
+	// RestoreOverrides are applied to the restored PersistentVolumeClaim after they are validated against the PersistentVolume
+	// +optional
+	RestoreOverrides *RestoreOverrides `json:"restoreOverrides,omitempty"`
+	// MigrateTo makes a restore copy the data into a new PersistentVolumeClaim in another StorageClass
+	// instead of binding the PersistentVolume again
+	// +optional
+	MigrateTo *Migration `json:"migrateTo,omitempty"`
+	// PersistentVolumeSpec is a sanitized copy of the spec of the PersistentVolume, used to re-create the
+	// PersistentVolume object if it is deleted while its backing volume is retained
+	// +optional
+	PersistentVolumeSpec *corev1.PersistentVolumeSpec `json:"persistentVolumeSpec,omitempty"`
 	// Restore indicates whether a restore should be performed to recover the deleted PVC and have it bound to the PV again
 	Restore bool `json:"restore"`
+	// Force allows a restore to take back a PersistentVolume which has since been bound to another claim
+	// +optional
+	Force bool `json:"force,omitempty"`
+	// DryRun makes a restore only render the changes it would make into status.restorePlan
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// ReleaseToPool indicates the PersistentVolume should be made Available for a new PersistentVolumeClaim
+	// instead of restoring the deleted one
+	// +optional
+	ReleaseToPool *PVCReclaimRelease `json:"releaseToPool,omitempty"`
+	// Purge indicates the released PersistentVolume and its backing volume should be deleted
+	// +optional
+	Purge bool `json:"purge,omitempty"`
+	// Archive uploads the contents of the released PersistentVolume to object storage, a purge waits for it
+	// and the reclaim is kept after the purge to restore the archive into a new PersistentVolumeClaim
+	// +optional
+	Archive *Archive `json:"archive,omitempty"`
+	// Preview mounts the released PersistentVolume read-only in an inspection pod until it times out or is unset
+	// +optional
+	Preview bool `json:"preview,omitempty"`
+	// LegalHold suspends purge and blocks deletion of the reclaim and its PersistentVolume while enabled
+	// +optional
+	LegalHold *LegalHold `json:"legalHold,omitempty"`
+}
+
+// LegalHoldStatus records who placed and lifted the legal hold on the reclaim
+type LegalHoldStatus struct {
+	// Active indicates whether the hold is currently in place
+	Active bool `json:"active"`
+	// PlacedBy identifies who placed the hold
+	PlacedBy string `json:"placedBy,omitempty"`
+	// PlacedAt is the time the hold was placed
+	PlacedAt *metav1.Time `json:"placedAt,omitempty"`
+	// LiftedBy identifies who lifted the hold
+	LiftedBy string `json:"liftedBy,omitempty"`
+	// LiftedAt is the time the hold was lifted
+	LiftedAt *metav1.Time `json:"liftedAt,omitempty"`
+	// Reason is the reason given when the hold was last placed or lifted
+	Reason string `json:"reason,omitempty"`
+	// PersistentVolumeReclaimPolicy is the reclaim policy of the PersistentVolume before it was forced to Retain
+	PersistentVolumeReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"persistentVolumeReclaimPolicy,omitempty"`
+}
+
+// PersistentVolumeDetails mirrors the state of the PersistentVolume for users without access to PersistentVolumes
+type PersistentVolumeDetails struct {
+	// Phase is the current phase of the PersistentVolume
+	Phase corev1.PersistentVolumePhase `json:"phase,omitempty"`
+	// ReclaimPolicy is the current reclaim policy of the PersistentVolume
+	ReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// Capacity is the storage capacity of the PersistentVolume
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+	// StorageClassName is the storage class of the PersistentVolume
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// ReleasedAt is the time the PersistentVolume entered the Released phase
+	// +optional
+	ReleasedAt *metav1.Time `json:"releasedAt,omitempty"`
+	// DeletionTimestamp is set when the PersistentVolume is terminating
+	// +optional
+	DeletionTimestamp *metav1.Time `json:"deletionTimestamp,omitempty"`
+}
+
+// PreflightCheckResult is the outcome of a single check run before a restore
+type PreflightCheckResult struct {
+	// Name identifies the check
+	Name string `json:"name"`
+	// Passed indicates whether the check allows the restore to proceed
+	Passed bool `json:"passed"`
+	// Message describes why the check failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// MigrationPhase is the stage a migration is at
+type MigrationPhase string
+
+const (
+	// MigrationCopying waits for the staging PersistentVolumeClaims to be bound and the copy Job to complete
+	MigrationCopying MigrationPhase = "Copying"
+	// MigrationHandingOver moves the copied PersistentVolume from the staging namespace to the restored PersistentVolumeClaim
+	MigrationHandingOver MigrationPhase = "HandingOver"
+	// MigrationSucceeded means the restored PersistentVolumeClaim holds the copied data
+	MigrationSucceeded MigrationPhase = "Succeeded"
+	// MigrationFailed means the copy Job failed
+	MigrationFailed MigrationPhase = "Failed"
+)
+
+// MigrationStatus reports the progress of a migration
+type MigrationStatus struct {
+	// Phase is the stage the migration is at
+	Phase MigrationPhase `json:"phase"`
+	// StorageClassName is the StorageClass the data is copied into
+	StorageClassName string `json:"storageClassName"`
+	// StagingNamespace is the namespace of the staging PersistentVolumeClaims and the copy Job
+	StagingNamespace string `json:"stagingNamespace"`
+	// SourceClaimName is the staging PersistentVolumeClaim bound to the released PersistentVolume
+	SourceClaimName string `json:"sourceClaimName"`
+	// TargetClaimName is the staging PersistentVolumeClaim provisioned in the new StorageClass
+	TargetClaimName string `json:"targetClaimName"`
+	// TargetPersistentVolumeName is the PersistentVolume provisioned for the target claim
+	// +optional
+	TargetPersistentVolumeName string `json:"targetPersistentVolumeName,omitempty"`
+	// TargetReclaimPolicy is the reclaim policy of the target PersistentVolume before it was handed over
+	// +optional
+	TargetReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"targetReclaimPolicy,omitempty"`
+	// JobName is the copy Job
+	JobName string `json:"jobName"`
+	// Attempts is the number of copy pods which have run to completion or failure
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+	// ExitCode is the exit code of the last copy pod which terminated
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+	// Message describes the progress or failure of the migration
+	// +optional
+	Message string `json:"message,omitempty"`
+	// StartTime is when the migration started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the migration succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// PreviewStatus records the inspection pod mounting the PersistentVolume read-only
+type PreviewStatus struct {
+	// ClaimName is the scratch PersistentVolumeClaim the PersistentVolume is bound to during the preview
+	ClaimName string `json:"claimName"`
+	// PodName is the inspection pod mounting the scratch PersistentVolumeClaim
+	PodName string `json:"podName"`
+	// StartTime is when the preview started
+	StartTime metav1.Time `json:"startTime"`
+	// ExpiryTime is when the preview is torn down
+	ExpiryTime metav1.Time `json:"expiryTime"`
+}
+
+// ContentManifestPhase is the stage a content manifest is at
+type ContentManifestPhase string
+
+const (
+	// ContentManifestScanning waits for the Job listing the content of the PersistentVolume to complete
+	ContentManifestScanning ContentManifestPhase = "Scanning"
+	// ContentManifestReleasing returns the PersistentVolume to the claimRef it had before the scan
+	ContentManifestReleasing ContentManifestPhase = "Releasing"
+	// ContentManifestCompleted means the manifest was recorded into the ConfigMap
+	ContentManifestCompleted ContentManifestPhase = "Completed"
+	// ContentManifestFailed means the scan failed, no manifest was recorded
+	ContentManifestFailed ContentManifestPhase = "Failed"
+)
+
+// ContentManifestStatus summarizes what is on the released PersistentVolume
+type ContentManifestStatus struct {
+	// Phase is the stage the content manifest is at
+	Phase ContentManifestPhase `json:"phase"`
+	// JobName is the Job scanning the PersistentVolume
+	JobName string `json:"jobName"`
+	// ConfigMapName is the ConfigMap holding the manifest, in the namespace of the reclaim
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// TotalBytes is the size of all files on the PersistentVolume
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// FileCount is the number of files on the PersistentVolume
+	// +optional
+	FileCount int64 `json:"fileCount,omitempty"`
+	// Checksums indicates whether the ConfigMap holds a sha256 checksum of every file
+	// +optional
+	Checksums bool `json:"checksums,omitempty"`
+	// Message describes the outcome of the scan
+	// +optional
+	Message string `json:"message,omitempty"`
+	// CompletionTime is when the PersistentVolume was returned to its claimRef
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// ArchivePhase is the stage an archive is at
+type ArchivePhase string
+
+const (
+	// ArchiveUploading waits for the Job uploading the contents of the PersistentVolume to complete
+	ArchiveUploading ArchivePhase = "Uploading"
+	// ArchiveReleasing returns the PersistentVolume to the claimRef it had before the upload
+	ArchiveReleasing ArchivePhase = "Releasing"
+	// ArchiveCompleted means the contents of the PersistentVolume are archived
+	ArchiveCompleted ArchivePhase = "Completed"
+	// ArchiveFailed means the upload failed, a purge is held until spec.archive is removed
+	ArchiveFailed ArchivePhase = "Failed"
+)
+
+// ArchiveStatus records where the contents of the PersistentVolume are archived
+type ArchiveStatus struct {
+	// Phase is the stage the archive is at
+	Phase ArchivePhase `json:"phase"`
+	// JobName is the Job uploading the archive
+	JobName string `json:"jobName"`
+	// Endpoint is the URL of the S3-compatible endpoint the archive is uploaded to
+	Endpoint string `json:"endpoint"`
+	// Bucket is the bucket holding the archive
+	Bucket string `json:"bucket"`
+	// ObjectKey is the key of the gzipped tarball in the bucket
+	ObjectKey string `json:"objectKey"`
+	// CredentialsSecretName is the Secret holding the credentials of the bucket
+	CredentialsSecretName string `json:"credentialsSecretName"`
+	// SHA256 is the checksum of the uploaded object
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+	// Message describes the outcome of the upload or of a restore from the archive
+	// +optional
+	Message string `json:"message,omitempty"`
+	// CompletionTime is when the archive was completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// RestoreJobName is the Job extracting the archive into the restored PersistentVolumeClaim
+	// +optional
+	RestoreJobName string `json:"restoreJobName,omitempty"`
+}
+
+// SnapshotReference records the VolumeSnapshot taken of the PersistentVolumeClaim when it was deleted
+type SnapshotReference struct {
+	// Name is the name of the VolumeSnapshot in the namespace of the reclaim
+	Name string `json:"name"`
+	// ContentName is the name of the VolumeSnapshotContent bound to the VolumeSnapshot
+	// +optional
+	ContentName string `json:"contentName,omitempty"`
+	// VolumeSnapshotClassName is the VolumeSnapshotClass the snapshot was taken with
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// CreationTime is when the snapshot was cut by the storage system
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+	// ReadyToUse indicates whether the snapshot can be restored from
+	// +optional
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+	// RestoreSize is the minimum size of a volume restored from the snapshot
+	// +optional
+	RestoreSize *resource.Quantity `json:"restoreSize,omitempty"`
+	// Error is the last error reported while taking the snapshot
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// RestorePlan describes the changes a restore would make, rendered by a dry run
+type RestorePlan struct {
+	// Ready indicates whether the restore would go ahead
+	Ready bool `json:"ready"`
+	// Blockers lists why the restore would be refused
+	// +optional
+	Blockers []string `json:"blockers,omitempty"`
+	// PersistentVolumeClaim is the YAML manifest of the PersistentVolumeClaim the restore would create
+	// +optional
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+	// PersistentVolume is the YAML manifest of the PersistentVolume the restore would re-create
+	// +optional
+	PersistentVolume string `json:"persistentVolume,omitempty"`
+	// PersistentVolumePatch is the JSON merge patch the restore would apply to the PersistentVolume
+	// +optional
+	PersistentVolumePatch string `json:"persistentVolumePatch,omitempty"`
+	// RemovedPersistentVolumeAnnotations are the annotations the restore would remove from the PersistentVolume
+	// +optional
+	RemovedPersistentVolumeAnnotations map[string]string `json:"removedPersistentVolumeAnnotations,omitempty"`
+	// PlannedAt is when the plan was last rendered
+	PlannedAt metav1.Time `json:"plannedAt"`
 }
 
 // PVCReclaimStatus defines the observed state of PVCReclaim
@@ -48,10 +421,60 @@ type PVCReclaimStatus struct {
 	Reason string `json:"reason,omitempty"`
 	// Message is used to provide additional information regarding the state of the reclaim resource
 	Message string `json:"message,omitempty"`
+	// ClaimUID is the UID of the deleted PersistentVolumeClaim the PersistentVolume is reserved for
+	ClaimUID types.UID `json:"claimUID,omitempty"`
+	// BoundClaimRef is the PersistentVolumeClaim that bound the PersistentVolume after it was released to the pool
+	// +optional
+	BoundClaimRef *corev1.ObjectReference `json:"boundClaimRef,omitempty"`
+	// LegalHold records the state of the legal hold on the reclaim
+	// +optional
+	LegalHold *LegalHoldStatus `json:"legalHold,omitempty"`
+	// PersistentVolume mirrors the current state of the PersistentVolume, unset while it does not exist
+	// +optional
+	PersistentVolume *PersistentVolumeDetails `json:"persistentVolume,omitempty"`
+	// PreflightChecks are the results of the checks run before the last restore
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	PreflightChecks []PreflightCheckResult `json:"preflightChecks,omitempty"`
+	// Snapshot is the VolumeSnapshot taken of the PersistentVolumeClaim before it was released,
+	// or found to have been taken of it, which a restore provisions from once the PV is gone
+	// +optional
+	Snapshot *SnapshotReference `json:"snapshot,omitempty"`
+	// RemovedPersistentVolumeAnnotations are the annotations removed from the PersistentVolume by the last restore
+	// +optional
+	RemovedPersistentVolumeAnnotations map[string]string `json:"removedPersistentVolumeAnnotations,omitempty"`
+	// Migration reports the progress of a restore into another StorageClass
+	// +optional
+	Migration *MigrationStatus `json:"migration,omitempty"`
+	// Preview records the running preview of the PersistentVolume
+	// +optional
+	Preview *PreviewStatus `json:"preview,omitempty"`
+	// ContentManifest summarizes what is on the PersistentVolume, scanned once it is Released
+	// +optional
+	ContentManifest *ContentManifestStatus `json:"contentManifest,omitempty"`
+	// Archive records where the contents of the PersistentVolume are archived
+	// +optional
+	Archive *ArchiveStatus `json:"archive,omitempty"`
+	// ConsumedBy is the PersistentVolumeClaim the PersistentVolume was bound to through a dataSourceRef to the reclaim
+	// +optional
+	ConsumedBy *corev1.ObjectReference `json:"consumedBy,omitempty"`
+	// RestorePlan is the plan rendered by the last dry run restore
+	// +optional
+	RestorePlan *RestorePlan `json:"restorePlan,omitempty"`
+	// Conditions represent the latest available observations of the reclaim resource
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="PV",type=string,JSONPath=`.spec.persistentVolumeRef.name`
+//+kubebuilder:printcolumn:name="PV Phase",type=string,JSONPath=`.status.persistentVolume.phase`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.recoverStatus`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // PVCReclaim is the Schema for the pvcreclaims API
 type PVCReclaim struct {
@@ -0,0 +1,197 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// claimRecordAnnotation holds the encoded identity and spec of the PVC a PV was released from
+	claimRecordAnnotation = "pvc-reclaim.yibozhuang.me/claim"
+	// abandonedAnnotation marks a PV whose PVCReclaim was deliberately deleted, with the
+	// <namespace>/<name> of the PVC it was released from, so it is not adopted again
+	abandonedAnnotation = "pvc-reclaim.yibozhuang.me/abandoned"
+	// claimRecordVersion prefixes the encoded claim record so the encoding can evolve
+	claimRecordVersion = "v1"
+	// reclaimMetadataPrefix is the prefix of labels and annotations owned by this controller
+	reclaimMetadataPrefix = "pvc-reclaim.yibozhuang.me/"
+)
+
+// claimRecord is the identity and spec of a PVC mirrored onto its PV, so the
+// PVCReclaim can be rebuilt even if the PVCReclaim object itself is lost
+type claimRecord struct {
+	Namespace   string                           `json:"ns"`
+	Name        string                           `json:"n"`
+	UID         types.UID                        `json:"u,omitempty"`
+	Labels      map[string]string                `json:"l,omitempty"`
+	Annotations map[string]string                `json:"a,omitempty"`
+	Spec        corev1.PersistentVolumeClaimSpec `json:"s"`
+}
+
+// newClaimRecord builds the claim record of the PVC tracked by the PVCReclaim,
+// leaving out the metadata owned by this controller
+func newClaimRecord(pvcReclaim *v1alpha1.PVCReclaim) *claimRecord {
+	return &claimRecord{
+		Namespace:   pvcReclaim.Namespace,
+		Name:        pvcReclaim.Name,
+		UID:         pvcReclaim.Status.ClaimUID,
+		Labels:      withoutReclaimMetadata(pvcReclaim.Labels),
+		Annotations: withoutReclaimMetadata(pvcReclaim.Annotations),
+		Spec:        pvcReclaim.Spec.PersistentVolumeClaimSpec,
+	}
+}
+
+// withoutReclaimMetadata returns a copy of the labels or annotations without the keys owned by this controller
+func withoutReclaimMetadata(in map[string]string) map[string]string {
+	var out map[string]string
+	for key, val := range in {
+		if strings.HasPrefix(key, reclaimMetadataPrefix) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(in))
+		}
+		out[key] = val
+	}
+	return out
+}
+
+// encodeClaimRecord encodes the claim record as <version>.<base64 gzipped json>
+func encodeClaimRecord(record *claimRecord) (string, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := zw.Write(raw); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return claimRecordVersion + "." + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decodeClaimRecord decodes a claim record produced by encodeClaimRecord
+func decodeClaimRecord(encoded string) (*claimRecord, error) {
+	version, payload, found := strings.Cut(encoded, ".")
+	if !found {
+		return nil, fmt.Errorf("malformed claim record")
+	}
+	if version != claimRecordVersion {
+		return nil, fmt.Errorf("unsupported claim record version %q", version)
+	}
+
+	compressed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var record claimRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, err
+	}
+	if record.Namespace == "" || record.Name == "" {
+		return nil, fmt.Errorf("claim record is missing the PVC namespace or name")
+	}
+	return &record, nil
+}
+
+// mirrorClaimRecord writes the claim record of the PVCReclaim onto the PV it
+// reserves, so the PVCReclaim can be adopted again from the PV alone
+func (r *PVCReclaimController) mirrorClaimRecord(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) error {
+	if pv.DeletionTimestamp != nil {
+		return nil
+	}
+	claimRef := pv.Spec.ClaimRef
+	if claimRef != nil && (claimRef.Namespace != pvcReclaim.Namespace || claimRef.Name != pvcReclaim.Name) {
+		return nil
+	}
+
+	encoded, err := encodeClaimRecord(newClaimRecord(pvcReclaim))
+	if err != nil {
+		return err
+	}
+	_, abandoned := pv.Annotations[abandonedAnnotation]
+	if pv.Annotations[claimRecordAnnotation] == encoded && !abandoned {
+		return nil
+	}
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	if pv.Annotations == nil {
+		pv.Annotations = make(map[string]string, 1)
+	}
+	pv.Annotations[claimRecordAnnotation] = encoded
+	// the PV is reserved by a PVCReclaim again
+	delete(pv.Annotations, abandonedAnnotation)
+	return r.client.Patch(ctx, pv, patch)
+}
+
+// abandonClaimRecord removes the claim record from the PV of a PVCReclaim whose
+// deletion was confirmed and leaves a tombstone in its place, so the adopter
+// does not bring the PVCReclaim back
+func (r *PVCReclaimController) abandonClaimRecord(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) error {
+	if pv.DeletionTimestamp != nil {
+		return nil
+	}
+	claimRef := pv.Spec.ClaimRef
+	if claimRef != nil && (claimRef.Namespace != pvcReclaim.Namespace || claimRef.Name != pvcReclaim.Name) {
+		return nil
+	}
+
+	tombstone := fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name)
+	_, recorded := pv.Annotations[claimRecordAnnotation]
+	if !recorded && pv.Annotations[abandonedAnnotation] == tombstone {
+		return nil
+	}
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	if pv.Annotations == nil {
+		pv.Annotations = make(map[string]string, 1)
+	}
+	delete(pv.Annotations, claimRecordAnnotation)
+	pv.Annotations[abandonedAnnotation] = tombstone
+	return r.client.Patch(ctx, pv, patch)
+}
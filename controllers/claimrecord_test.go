@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestClaimRecord_RoundTrip(t *testing.T) {
+	reclaim, _ := newReleasedFixtures()
+	reclaim.Labels["app"] = "db"
+	reclaim.Annotations = map[string]string{"owner": "team-a"}
+
+	encoded, err := encodeClaimRecord(newClaimRecord(reclaim))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^v1\.`, encoded)
+
+	record, err := decodeClaimRecord(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", record.Namespace)
+	assert.Equal(t, "test-reclaim", record.Name)
+	assert.Equal(t, types.UID("old-pvc-uid"), record.UID)
+	assert.Equal(t, map[string]string{"app": "db"}, record.Labels)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, record.Annotations)
+	assert.Equal(t, reclaim.Spec.PersistentVolumeClaimSpec, record.Spec)
+}
+
+func TestClaimRecord_UnsupportedVersion(t *testing.T) {
+	_, err := decodeClaimRecord("v0.H4sIAAAAAAAA")
+	assert.Error(t, err)
+
+	_, err = decodeClaimRecord("not-a-record")
+	assert.Error(t, err)
+}
+
+func TestPVCReclaimController_Reconcile_MirrorsClaimRecord(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	record, err := decodeClaimRecord(updatedPV.Annotations[claimRecordAnnotation])
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test-reclaim", record.Name)
+		assert.Equal(t, types.UID("old-pvc-uid"), record.UID)
+	}
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// namespaceTerminatingRequeue is how long a restore waits for a namespace still being deleted
+const namespaceTerminatingRequeue = 10 * time.Second
+
+// ClusterPVCReclaimController reconciles a ClusterPVCReclaim object
+type ClusterPVCReclaimController struct {
+	client client.Client
+}
+
+var _ reconcile.Reconciler = &ClusterPVCReclaimController{}
+
+func NewClusterPVCReclaimController(client client.Client) *ClusterPVCReclaimController {
+	return &ClusterPVCReclaimController{
+		client: client,
+	}
+}
+
+//+kubebuilder:rbac:groups=yibozhuang.me,resources=clusterpvcreclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=yibozhuang.me,resources=clusterpvcreclaims/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=yibozhuang.me,resources=clusterpvcreclaims/finalizers,verbs=update
+
+// Reconcile re-creates the namespace and the PVCReclaim kept by a
+// ClusterPVCReclaim when a restore is requested. The PVCReclaim is created with
+// restore set, so the deleted PVC is restored by the PVCReclaimController, and
+// the ClusterPVCReclaim is deleted once it has handed over. Until then the
+// ClusterPVCReclaim keeps the PV reserved for the deleted PVC and enforces the
+// legal hold of the PVCReclaim it keeps.
+func (r *ClusterPVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var clusterPVCReclaim v1alpha1.ClusterPVCReclaim
+	if err := r.client.Get(ctx, req.NamespacedName, &clusterPVCReclaim); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	namespaceName := clusterPVCReclaim.Spec.Namespace.Name
+	template := clusterPVCReclaim.Spec.PVCReclaim
+
+	var pv *corev1.PersistentVolume
+	if template.Spec.PersistentVolumeRef != nil {
+		var pvRef corev1.PersistentVolume
+		err := r.client.Get(ctx, types.NamespacedName{Name: template.Spec.PersistentVolumeRef.Name}, &pvRef)
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err == nil {
+			pv = &pvRef
+		}
+	}
+
+	if err := r.syncLegalHold(ctx, &clusterPVCReclaim, pv); err != nil {
+		return ctrl.Result{}, err
+	}
+	if clusterPVCReclaim.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.handleDeletion(ctx, &clusterPVCReclaim)
+	}
+	if pv != nil {
+		if err := r.reserveClaimRef(ctx, &clusterPVCReclaim, pv); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !clusterPVCReclaim.Spec.Restore {
+		return ctrl.Result{}, nil
+	}
+
+	var namespace corev1.Namespace
+	err := r.client.Get(ctx, types.NamespacedName{Name: namespaceName}, &namespace)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && namespace.DeletionTimestamp != nil {
+		patch := client.MergeFrom(clusterPVCReclaim.DeepCopy())
+		clusterPVCReclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
+		clusterPVCReclaim.Status.Message = fmt.Sprintf("Waiting for namespace %s to finish terminating", namespaceName)
+		if err := r.client.Status().Patch(ctx, &clusterPVCReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: namespaceTerminatingRequeue}, nil
+	}
+	if errors.IsNotFound(err) {
+		namespace = corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        namespaceName,
+				Labels:      clusterPVCReclaim.Spec.Namespace.Labels,
+				Annotations: clusterPVCReclaim.Spec.Namespace.Annotations,
+			},
+		}
+		logger.Info("Re-creating namespace of ClusterPVCReclaim", "namespace", namespaceName, "ClusterPVCReclaim", clusterPVCReclaim.Name)
+		if err := r.client.Create(ctx, &namespace); err != nil && !errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, r.failRestore(ctx, &clusterPVCReclaim, fmt.Sprintf("Failed to re-create namespace %s, error: %v", namespaceName, err), err)
+		}
+	}
+
+	pvcReclaim := v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        template.Name,
+			Namespace:   namespaceName,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	pvcReclaim.Spec.Restore = true
+	pvcReclaim.Spec.ReleaseToPool = nil
+	pvcReclaim.Spec.Purge = false
+	pvcReclaim.Spec.DryRun = false
+	logger.Info("Re-creating PVCReclaim from ClusterPVCReclaim", "PVCReclaim", fmt.Sprintf("%s/%s", namespaceName, template.Name), "ClusterPVCReclaim", clusterPVCReclaim.Name)
+	if err := r.client.Create(ctx, &pvcReclaim); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, r.failRestore(ctx, &clusterPVCReclaim, fmt.Sprintf("Failed to re-create PVCReclaim %s/%s, error: %v", namespaceName, template.Name, err), err)
+		}
+	} else {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.NotRecovered
+		pvcReclaim.Status.ClaimUID = template.ClaimUID
+		pvcReclaim.Status.LegalHold = template.LegalHold.DeepCopy()
+		pvcReclaim.Status.Message = fmt.Sprintf("PVCReclaim re-created from ClusterPVCReclaim %s", clusterPVCReclaim.Name)
+		if err := r.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	patch := client.MergeFrom(clusterPVCReclaim.DeepCopy())
+	clusterPVCReclaim.Status.RecoverStatus = v1alpha1.RecoverySuccess
+	clusterPVCReclaim.Status.Reason = ""
+	clusterPVCReclaim.Status.Message = fmt.Sprintf("Re-created namespace %s and PVCReclaim %s/%s", namespaceName, namespaceName, template.Name)
+	if err := r.client.Status().Patch(ctx, &clusterPVCReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the re-created PVCReclaim has taken over the legal hold
+	patch = client.MergeFrom(clusterPVCReclaim.DeepCopy())
+	if controllerutil.RemoveFinalizer(&clusterPVCReclaim, legalHoldFinalizer) {
+		if err := r.client.Patch(ctx, &clusterPVCReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	logger.Info("Deleting ClusterPVCReclaim after handing over to PVCReclaim", "ClusterPVCReclaim", clusterPVCReclaim.Name)
+	return ctrl.Result{}, client.IgnoreNotFound(r.client.Delete(ctx, &clusterPVCReclaim))
+}
+
+// clusterLegalHoldActive reports whether the PVCReclaim kept by the ClusterPVCReclaim is under a legal hold
+func clusterLegalHoldActive(clusterPVCReclaim *v1alpha1.ClusterPVCReclaim) bool {
+	legalHold := clusterPVCReclaim.Spec.PVCReclaim.Spec.LegalHold
+	return legalHold != nil && legalHold.Enabled
+}
+
+// syncLegalHold enforces the legal hold of the kept PVCReclaim the way the
+// PVCReclaimController would: the legal hold finalizer is kept on the
+// ClusterPVCReclaim and its PV and the PV is forced to the Retain reclaim
+// policy. Lifting the hold in spec.pvcReclaim.spec.legalHold restores the
+// reclaim policy and releases both. Once the PVCReclaim has been re-created it
+// owns the hold and the PV is left alone. A hold requested once the
+// ClusterPVCReclaim is being deleted is not placed and reported in its status
+// message instead. pv is nil when the PV no longer exists.
+func (r *ClusterPVCReclaimController) syncLegalHold(ctx context.Context, clusterPVCReclaim *v1alpha1.ClusterPVCReclaim, pv *corev1.PersistentVolume) error {
+	logger := log.FromContext(ctx)
+	handedOver := clusterPVCReclaim.Status.RecoverStatus == v1alpha1.RecoverySuccess
+	active := clusterLegalHoldActive(clusterPVCReclaim) && !handedOver
+
+	if active && clusterPVCReclaim.DeletionTimestamp != nil && !controllerutil.ContainsFinalizer(clusterPVCReclaim, legalHoldFinalizer) {
+		requestedBy := clusterPVCReclaim.Spec.PVCReclaim.Spec.LegalHold.RequestedBy
+		message := fmt.Sprintf("Legal hold requested by %s was not placed as the ClusterPVCReclaim is being deleted", requestedBy)
+		if clusterPVCReclaim.Status.Message == message {
+			return nil
+		}
+		logger.Info("Not placing legal hold on ClusterPVCReclaim being deleted", "ClusterPVCReclaim", clusterPVCReclaim.Name, "by", requestedBy)
+		patch := client.MergeFrom(clusterPVCReclaim.DeepCopy())
+		clusterPVCReclaim.Status.Message = message
+		return r.client.Status().Patch(ctx, clusterPVCReclaim, patch)
+	}
+
+	previous := clusterPVCReclaim.DeepCopy()
+	template := &clusterPVCReclaim.Spec.PVCReclaim
+	if pv != nil && !handedOver {
+		pvPatch := client.MergeFrom(pv.DeepCopy())
+		changed := false
+		if active {
+			changed = controllerutil.AddFinalizer(pv, legalHoldFinalizer)
+			if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+				if template.LegalHold == nil {
+					template.LegalHold = &v1alpha1.LegalHoldStatus{
+						Active:   true,
+						PlacedBy: template.Spec.LegalHold.RequestedBy,
+						Reason:   template.Spec.LegalHold.Reason,
+					}
+				}
+				template.LegalHold.PersistentVolumeReclaimPolicy = pv.Spec.PersistentVolumeReclaimPolicy
+				pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+				changed = true
+			}
+		} else if controllerutil.RemoveFinalizer(pv, legalHoldFinalizer) {
+			changed = true
+			if template.LegalHold != nil && template.LegalHold.PersistentVolumeReclaimPolicy != "" {
+				pv.Spec.PersistentVolumeReclaimPolicy = template.LegalHold.PersistentVolumeReclaimPolicy
+			}
+		}
+		if changed {
+			if err := r.client.Patch(ctx, pv, pvPatch); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !active && !handedOver && template.LegalHold != nil && template.LegalHold.Active {
+		now := metav1.Now()
+		template.LegalHold.Active = false
+		template.LegalHold.LiftedAt = &now
+		template.LegalHold.LiftedBy = ""
+		template.LegalHold.Reason = ""
+		if template.Spec.LegalHold != nil {
+			template.LegalHold.LiftedBy = template.Spec.LegalHold.RequestedBy
+			template.LegalHold.Reason = template.Spec.LegalHold.Reason
+		}
+		template.LegalHold.PersistentVolumeReclaimPolicy = ""
+		logger.Info("Legal hold lifted from ClusterPVCReclaim", "ClusterPVCReclaim", clusterPVCReclaim.Name, "by", template.LegalHold.LiftedBy)
+	}
+
+	if active {
+		controllerutil.AddFinalizer(clusterPVCReclaim, legalHoldFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(clusterPVCReclaim, legalHoldFinalizer)
+	}
+	if equality.Semantic.DeepEqual(previous, clusterPVCReclaim) {
+		return nil
+	}
+	return r.client.Patch(ctx, clusterPVCReclaim, client.MergeFrom(previous))
+}
+
+// handleDeletion reports a deletion of the ClusterPVCReclaim held back by the legal hold
+func (r *ClusterPVCReclaimController) handleDeletion(ctx context.Context, clusterPVCReclaim *v1alpha1.ClusterPVCReclaim) error {
+	if !controllerutil.ContainsFinalizer(clusterPVCReclaim, legalHoldFinalizer) {
+		return nil
+	}
+	placedBy := clusterPVCReclaim.Spec.PVCReclaim.Spec.LegalHold.RequestedBy
+	if clusterPVCReclaim.Spec.PVCReclaim.LegalHold != nil && clusterPVCReclaim.Spec.PVCReclaim.LegalHold.PlacedBy != "" {
+		placedBy = clusterPVCReclaim.Spec.PVCReclaim.LegalHold.PlacedBy
+	}
+	message := fmt.Sprintf("Deletion of ClusterPVCReclaim is blocked by legal hold placed by %s", placedBy)
+	if clusterPVCReclaim.Status.Message == message {
+		return nil
+	}
+	patch := client.MergeFrom(clusterPVCReclaim.DeepCopy())
+	clusterPVCReclaim.Status.Message = message
+	return r.client.Status().Patch(ctx, clusterPVCReclaim, patch)
+}
+
+// reserveClaimRef keeps the claimRef of a PV that is not Bound pinned to the
+// deleted PVC while only the ClusterPVCReclaim is left to reserve it
+func (r *ClusterPVCReclaimController) reserveClaimRef(ctx context.Context, clusterPVCReclaim *v1alpha1.ClusterPVCReclaim, pv *corev1.PersistentVolume) error {
+	logger := log.FromContext(ctx)
+
+	if pv.Status.Phase == corev1.VolumeBound || pv.DeletionTimestamp != nil || clusterPVCReclaim.Status.RecoverStatus == v1alpha1.RecoverySuccess {
+		return nil
+	}
+	namespaceName := clusterPVCReclaim.Spec.Namespace.Name
+	template := clusterPVCReclaim.Spec.PVCReclaim
+	claimRef := pv.Spec.ClaimRef
+	if claimRef != nil && claimRef.Namespace == namespaceName && claimRef.Name == template.Name {
+		return nil
+	}
+
+	logger.Info("PV claimRef drifted from the original PVC, re-asserting it", "pv", pv.Name, "ClusterPVCReclaim", clusterPVCReclaim.Name)
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.ClaimRef = &corev1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  namespaceName,
+		Name:       template.Name,
+		UID:        template.ClaimUID,
+	}
+	return r.client.Patch(ctx, pv, patch)
+}
+
+// failRestore records the failed restore on the ClusterPVCReclaim and returns the original error
+func (r *ClusterPVCReclaimController) failRestore(ctx context.Context, clusterPVCReclaim *v1alpha1.ClusterPVCReclaim, reason string, err error) error {
+	patch := client.MergeFrom(clusterPVCReclaim.DeepCopy())
+	clusterPVCReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+	clusterPVCReclaim.Status.Reason = reason
+	if innerErr := r.client.Status().Patch(ctx, clusterPVCReclaim, patch); innerErr != nil {
+		return innerErr
+	}
+	return err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterPVCReclaimController) SetupWithManager(mgr ctrl.Manager) error {
+	pvEnqueueClusterPVCReclaimReconcileRequestMapFunc := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
+		pv, ok := object.(*corev1.PersistentVolume)
+		if !ok {
+			return nil
+		}
+
+		var clusterPVCReclaims v1alpha1.ClusterPVCReclaimList
+		if err := r.client.List(context.Background(), &clusterPVCReclaims, client.MatchingLabels{
+			reclaimPVLabel: pv.Name,
+		}); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, clusterPVCReclaim := range clusterPVCReclaims.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: clusterPVCReclaim.Name},
+			})
+		}
+		return requests
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterPVCReclaim{}).
+		Watches(&corev1.PersistentVolume{}, pvEnqueueClusterPVCReclaimReconcileRequestMapFunc).
+		Complete(r)
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newClusterPVCReclaimFixture() *v1alpha1.ClusterPVCReclaim {
+	return &v1alpha1.ClusterPVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a.test-reclaim",
+		},
+		Spec: v1alpha1.ClusterPVCReclaimSpec{
+			Namespace: v1alpha1.ReclaimNamespace{
+				Name:   "team-a",
+				Labels: map[string]string{"team": "a"},
+			},
+			PVCReclaim: v1alpha1.PVCReclaimTemplate{
+				Name:   "test-reclaim",
+				Labels: map[string]string{reclaimPVLabel: "test-pv"},
+				Spec: v1alpha1.PVCReclaimSpec{
+					PersistentVolumeRef: &corev1.ObjectReference{
+						Kind:       "PersistentVolume",
+						APIVersion: "v1",
+						Name:       "test-pv",
+					},
+				},
+				ClaimUID: types.UID("old-pvc-uid"),
+			},
+			Restore: true,
+		},
+	}
+}
+
+func TestClusterPVCReclaimController_Reconcile_Restore(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	clusterPVCReclaim := newClusterPVCReclaimFixture()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(clusterPVCReclaim, &v1alpha1.PVCReclaim{}).WithObjects(clusterPVCReclaim).Build()
+	controller := NewClusterPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "team-a.test-reclaim"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var namespace corev1.Namespace
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "team-a"}, &namespace))
+	assert.Equal(t, "a", namespace.Labels["team"])
+
+	var pvcReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "team-a"}, &pvcReclaim))
+	assert.True(t, pvcReclaim.Spec.Restore)
+	assert.Equal(t, "test-pv", pvcReclaim.Spec.PersistentVolumeRef.Name)
+	assert.Equal(t, types.UID("old-pvc-uid"), pvcReclaim.Status.ClaimUID)
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &v1alpha1.ClusterPVCReclaim{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestClusterPVCReclaimController_Reconcile_NamespaceTerminating(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	now := metav1.Now()
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "team-a",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"kubernetes"},
+		},
+	}
+	clusterPVCReclaim := newClusterPVCReclaimFixture()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(clusterPVCReclaim).WithObjects(clusterPVCReclaim, namespace).Build()
+	controller := NewClusterPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "team-a.test-reclaim"}}
+	result, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, namespaceTerminatingRequeue, result.RequeueAfter)
+
+	var updated v1alpha1.ClusterPVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updated))
+	assert.Equal(t, v1alpha1.RecoveryInProgress, updated.Status.RecoverStatus)
+}
+
+func TestClusterPVCReclaimController_Reconcile_LegalHold(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	clusterPVCReclaim := newClusterPVCReclaimFixture()
+	clusterPVCReclaim.Spec.Restore = false
+	clusterPVCReclaim.Finalizers = []string{legalHoldFinalizer}
+	clusterPVCReclaim.Spec.PVCReclaim.Spec.LegalHold = &v1alpha1.LegalHold{Enabled: true, RequestedBy: "compliance@example.com"}
+	clusterPVCReclaim.Spec.PVCReclaim.LegalHold = &v1alpha1.LegalHoldStatus{
+		Active:                        true,
+		PlacedBy:                      "compliance@example.com",
+		PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+	}
+	_, pv := newReleasedFixtures()
+	pv.Finalizers = []string{legalHoldFinalizer}
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+	pv.Spec.ClaimRef = nil
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(clusterPVCReclaim, pv).WithObjects(clusterPVCReclaim, pv).Build()
+	controller := NewClusterPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "team-a.test-reclaim"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	// the PV stays reserved for the deleted PVC
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "team-a", updatedPV.Spec.ClaimRef.Namespace)
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+	assert.Equal(t, types.UID("old-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+
+	var updated v1alpha1.ClusterPVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updated))
+	assert.NoError(t, fakeClient.Delete(context.Background(), &updated))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updated))
+	assert.Contains(t, updated.Status.Message, "blocked by legal hold placed by compliance@example.com")
+
+	// lifting the hold restores the reclaim policy and lets the deletion complete
+	updated.Spec.PVCReclaim.Spec.LegalHold.Enabled = false
+	assert.NoError(t, fakeClient.Update(context.Background(), &updated))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Empty(t, updatedPV.Finalizers)
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updated)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestClusterPVCReclaimController_Reconcile_LegalHoldRejectedWhileDeleting(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	now := metav1.Now()
+	clusterPVCReclaim := newClusterPVCReclaimFixture()
+	clusterPVCReclaim.Spec.Restore = false
+	clusterPVCReclaim.DeletionTimestamp = &now
+	clusterPVCReclaim.Finalizers = []string{"example.com/backup"}
+	clusterPVCReclaim.Spec.PVCReclaim.Spec.LegalHold = &v1alpha1.LegalHold{Enabled: true, RequestedBy: "compliance@example.com"}
+	_, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(clusterPVCReclaim, pv).WithObjects(clusterPVCReclaim, pv).Build()
+	controller := NewClusterPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "team-a.test-reclaim"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updated v1alpha1.ClusterPVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updated))
+	assert.Equal(t, []string{"example.com/backup"}, updated.Finalizers)
+	assert.Nil(t, updated.Spec.PVCReclaim.LegalHold)
+	assert.Equal(t, "Legal hold requested by compliance@example.com was not placed as the ClusterPVCReclaim is being deleted", updated.Status.Message)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Empty(t, updatedPV.Finalizers)
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+}
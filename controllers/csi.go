@@ -0,0 +1,75 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const csiTimeout = 2 * time.Minute
+
+// errCSIDriverMismatch is returned when the CSI controller socket is served by
+// a different driver than the one which provisioned the volume
+type errCSIDriverMismatch struct {
+	want, got string
+}
+
+func (e *errCSIDriverMismatch) Error() string {
+	return fmt.Sprintf("CSI controller endpoint serves driver %s, volume belongs to driver %s", e.got, e.want)
+}
+
+// csiTarget turns a CSI endpoint such as /csi/csi.sock or unix:///csi/csi.sock into a gRPC target
+func csiTarget(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	return "unix://" + endpoint
+}
+
+// deleteCSIVolume calls DeleteVolume on the CSI controller served at the given
+// endpoint, after checking the endpoint belongs to the driver of the volume
+func deleteCSIVolume(ctx context.Context, endpoint, driver, volumeHandle string, secrets map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, csiTimeout)
+	defer cancel()
+
+	conn, err := grpc.NewClient(csiTarget(endpoint), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	info, err := csi.NewIdentityClient(conn).GetPluginInfo(ctx, &csi.GetPluginInfoRequest{})
+	if err != nil {
+		return err
+	}
+	if info.GetName() != driver {
+		return &errCSIDriverMismatch{want: driver, got: info.GetName()}
+	}
+
+	_, err = csi.NewControllerClient(conn).DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+		VolumeId: volumeHandle,
+		Secrets:  secrets,
+	})
+	return err
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// Drift types reported by the drift sweep
+const (
+	driftMissingPersistentVolume = "missing_pv"
+	driftUnclaimedBoundPVC       = "bound_pvc_without_reclaim"
+	driftDuplicateReclaim        = "duplicate_reclaim"
+)
+
+var driftGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "pvc_reclaim_drift",
+	Help: "Number of drifted objects found by the last drift sweep, by type of drift",
+}, []string{"type"})
+
+func init() {
+	metrics.Registry.MustRegister(driftGauge)
+}
+
+// driftReport counts the drift found by a sweep
+type driftReport map[string]int
+
+// DriftSweeper periodically cross-checks PVs, PVCs and PVCReclaims for drift
+// left behind by missed events or manual edits. PVCReclaims pointing at a
+// missing PV and Bound PVCs without a PVCReclaim are handed to the
+// PVCReclaimController through resync events, which repairs them the same way
+// it would have on the missed event. Several PVCReclaims for one PV cannot be
+// repaired safely and are flagged with the Duplicate condition instead.
+type DriftSweeper struct {
+	client   client.Client
+	resync   chan<- event.GenericEvent
+	interval time.Duration
+}
+
+var _ manager.LeaderElectionRunnable = &DriftSweeper{}
+
+// DriftSweeperOption configures optional behavior of the DriftSweeper
+type DriftSweeperOption func(*DriftSweeper)
+
+// WithDriftSweepInterval sets how often the drift sweep runs, a zero interval disables it
+func WithDriftSweepInterval(interval time.Duration) DriftSweeperOption {
+	return func(d *DriftSweeper) {
+		d.interval = interval
+	}
+}
+
+func NewDriftSweeper(client client.Client, resync chan<- event.GenericEvent, opts ...DriftSweeperOption) *DriftSweeper {
+	d := &DriftSweeper{
+		client: client,
+		resync: resync,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Start runs the drift sweep once the caches of the manager are synced and
+// then at every interval until the manager stops
+func (d *DriftSweeper) Start(ctx context.Context) error {
+	if d.interval <= 0 {
+		return nil
+	}
+
+	d.report(ctx)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.report(ctx)
+		}
+	}
+}
+
+// report runs a drift sweep and exports the drift it found
+func (d *DriftSweeper) report(ctx context.Context) {
+	report, err := d.sweep(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Drift sweep failed")
+		return
+	}
+	for driftType, count := range report {
+		driftGauge.WithLabelValues(driftType).Set(float64(count))
+	}
+}
+
+// NeedLeaderElection makes sure only the leader sweeps for drift
+func (d *DriftSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// sweep finds drift between PVs, PVCs and PVCReclaims, queues what can be
+// repaired for the PVCReclaimController and flags duplicate PVCReclaims
+func (d *DriftSweeper) sweep(ctx context.Context) (driftReport, error) {
+	logger := log.FromContext(ctx)
+	report := driftReport{
+		driftMissingPersistentVolume: 0,
+		driftUnclaimedBoundPVC:       0,
+		driftDuplicateReclaim:        0,
+	}
+
+	var pvs corev1.PersistentVolumeList
+	if err := d.client.List(ctx, &pvs); err != nil {
+		return nil, err
+	}
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := d.client.List(ctx, &pvcs); err != nil {
+		return nil, err
+	}
+	var pvcReclaims v1alpha1.PVCReclaimList
+	if err := d.client.List(ctx, &pvcReclaims); err != nil {
+		return nil, err
+	}
+
+	pvsByName := make(map[string]*corev1.PersistentVolume, len(pvs.Items))
+	for i := range pvs.Items {
+		pvsByName[pvs.Items[i].Name] = &pvs.Items[i]
+	}
+	reclaimsByPV := make(map[string][]*v1alpha1.PVCReclaim)
+	reclaimKeys := make(map[types.NamespacedName]bool, len(pvcReclaims.Items))
+	for i := range pvcReclaims.Items {
+		pvcReclaim := &pvcReclaims.Items[i]
+		reclaimKeys[types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: pvcReclaim.Name}] = true
+		if pvcReclaim.DeletionTimestamp != nil {
+			continue
+		}
+		pvName := pvcReclaim.Spec.PersistentVolumeRef.Name
+		reclaimsByPV[pvName] = append(reclaimsByPV[pvName], pvcReclaim)
+		// a PVCReclaim kept for a restore after its PV was deleted is not drift
+		kept := meta.IsStatusConditionTrue(pvcReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing)
+		if _, found := pvsByName[pvName]; !found && !kept {
+			report[driftMissingPersistentVolume]++
+			logger.Info("PVCReclaim points at a missing PV, queueing it for reconcile", "pv", pvName, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+			if err := d.enqueue(ctx, pvcReclaim.Namespace, pvcReclaim.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.Status.Phase != corev1.ClaimBound || pvc.Spec.VolumeName == "" || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		// staging, preview, manifest and archive PVCs are owned by a PVCReclaim already
+		if transientClaim(pvc) {
+			continue
+		}
+		if reclaimKeys[types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}] {
+			continue
+		}
+		report[driftUnclaimedBoundPVC]++
+		logger.Info("Bound PVC has no PVCReclaim, queueing it for reconcile", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
+		if err := d.enqueue(ctx, pvc.Namespace, pvc.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	for pvName, reclaims := range reclaimsByPV {
+		duplicated := len(reclaims) > 1
+		if duplicated {
+			report[driftDuplicateReclaim] += len(reclaims) - 1
+		}
+		if err := d.syncDuplicates(ctx, pvsByName[pvName], pvName, reclaims, duplicated); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// syncDuplicates raises the Duplicate condition on every PVCReclaim of the PV
+// which is not the one its claimRef points to, or on all of them when the
+// claimRef points to none, and clears it once the PV is no longer shared.
+// pv is nil when the PV does not exist.
+func (d *DriftSweeper) syncDuplicates(ctx context.Context, pv *corev1.PersistentVolume, pvName string, reclaims []*v1alpha1.PVCReclaim, duplicated bool) error {
+	var owner *v1alpha1.PVCReclaim
+	if duplicated && pv != nil && pv.Spec.ClaimRef != nil {
+		for _, pvcReclaim := range reclaims {
+			if pvcReclaim.Namespace == pv.Spec.ClaimRef.Namespace && pvcReclaim.Name == pv.Spec.ClaimRef.Name {
+				owner = pvcReclaim
+			}
+		}
+	}
+
+	for _, pvcReclaim := range reclaims {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		var changed bool
+		if !duplicated || pvcReclaim == owner {
+			changed = meta.RemoveStatusCondition(&pvcReclaim.Status.Conditions, v1alpha1.ConditionDuplicate)
+		} else {
+			condition := metav1.Condition{
+				Type:               v1alpha1.ConditionDuplicate,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: pvcReclaim.Generation,
+				Reason:             "AmbiguousOwner",
+				Message:            fmt.Sprintf("%d PVCReclaims reference PV %s and its claimRef points to none of them", len(reclaims), pvName),
+			}
+			if owner != nil {
+				condition.Reason = "PersistentVolumeOwnedByAnotherReclaim"
+				condition.Message = fmt.Sprintf("PV %s is also referenced by PVCReclaim %s/%s which its claimRef points to", pvName, owner.Namespace, owner.Name)
+			}
+			changed = meta.SetStatusCondition(&pvcReclaim.Status.Conditions, condition)
+		}
+		if !changed {
+			continue
+		}
+		if err := d.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue hands the PVCReclaim with the namespace and name to the PVCReclaimController
+func (d *DriftSweeper) enqueue(ctx context.Context, namespace, name string) error {
+	pvcReclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+	select {
+	case d.resync <- event.GenericEvent{Object: pvcReclaim}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetupWithManager registers the sweeper to run while the Manager is running.
+func (d *DriftSweeper) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(d)
+}
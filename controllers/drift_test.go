@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestDriftSweeper_Sweep(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	duplicate := reclaim.DeepCopy()
+	duplicate.Name = "duplicate-reclaim"
+	missing := reclaim.DeepCopy()
+	missing.Name = "missing-reclaim"
+	missing.Spec.PersistentVolumeRef.Name = "missing-pv"
+	kept := missing.DeepCopy()
+	kept.Name = "kept-reclaim"
+	kept.Spec.PersistentVolumeRef.Name = "deleted-pv"
+	kept.Status.Conditions = []metav1.Condition{{Type: v1alpha1.ConditionPersistentVolumeMissing, Status: metav1.ConditionTrue, Reason: "PersistentVolumeDeleted"}}
+	boundPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bound-pvc",
+			Namespace: "default",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: "other-pv",
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, duplicate, missing, kept).WithObjects(reclaim, duplicate, missing, kept, pv, boundPVC).Build()
+
+	resync := make(chan event.GenericEvent, 10)
+	sweeper := NewDriftSweeper(fakeClient, resync)
+	report, err := sweeper.sweep(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, driftReport{
+		driftMissingPersistentVolume: 1,
+		driftUnclaimedBoundPVC:       1,
+		driftDuplicateReclaim:        1,
+	}, report)
+
+	close(resync)
+	var queued []types.NamespacedName
+	for e := range resync {
+		queued = append(queued, types.NamespacedName{Namespace: e.Object.GetNamespace(), Name: e.Object.GetName()})
+	}
+	assert.ElementsMatch(t, []types.NamespacedName{
+		{Namespace: "default", Name: "missing-reclaim"},
+		{Namespace: "default", Name: "bound-pvc"},
+	}, queued)
+
+	// the PV claimRef points to test-reclaim, so only the other one is flagged
+	var updated v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "duplicate-reclaim", Namespace: "default"}, &updated))
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionDuplicate)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, "PersistentVolumeOwnedByAnotherReclaim", condition.Reason)
+	}
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "default"}, &updated))
+	assert.Nil(t, meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.ConditionDuplicate))
+}
+
+func TestDriftSweeper_Sweep_SkipsStagingPVC(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	stagingPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim-migrate",
+			Namespace: "default",
+			Labels:    map[string]string{migrationLabel: "test-reclaim"},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: "test-pv",
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim).WithObjects(reclaim, pv, stagingPVC).Build()
+
+	resync := make(chan event.GenericEvent, 10)
+	sweeper := NewDriftSweeper(fakeClient, resync)
+	report, err := sweeper.sweep(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report[driftUnclaimedBoundPVC])
+	assert.Empty(t, resync)
+}
+
+func TestDriftSweeper_StartSweepsImmediately(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, _ := newReleasedFixtures()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim).WithObjects(reclaim).Build()
+
+	resync := make(chan event.GenericEvent, 10)
+	sweeper := NewDriftSweeper(fakeClient, resync, WithDriftSweepInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = sweeper.Start(ctx)
+	}()
+
+	select {
+	case e := <-resync:
+		assert.Equal(t, "test-reclaim", e.Object.GetName())
+	case <-time.After(5 * time.Second):
+		t.Fatal("drift sweep did not run on startup")
+	}
+}
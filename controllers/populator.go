@@ -0,0 +1,260 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// volumePopulatorName is the name of the VolumePopulator registering PVCReclaim as a data source
+	volumePopulatorName = "pvc-reclaim"
+	// populatorRetryInterval is how often a PVC which cannot be populated yet is retried
+	populatorRetryInterval = 30 * time.Second
+)
+
+// volumePopulatorGVK is the kind registering volume populators with the volume data source validator
+var volumePopulatorGVK = schema.GroupVersionKind{Group: "populator.storage.k8s.io", Version: "v1beta1", Kind: "VolumePopulator"}
+
+// PVCReclaimPopulator populates PVCs whose dataSourceRef is a PVCReclaim by
+// binding the PV retained by the PVCReclaim to them, so a restore can be
+// written as a plain PVC manifest instead of setting restore on the PVCReclaim
+type PVCReclaimPopulator struct {
+	client             client.Client
+	pvAnnotationPolicy PersistentVolumeAnnotationPolicy
+}
+
+var _ reconcile.Reconciler = &PVCReclaimPopulator{}
+
+// PVCReclaimPopulatorOption configures optional behavior of the PVCReclaimPopulator
+type PVCReclaimPopulatorOption func(*PVCReclaimPopulator)
+
+// WithPopulatorAnnotationPolicy sets which PV annotations are removed when the PV is bound, DefaultPersistentVolumeAnnotationPolicy by default
+func WithPopulatorAnnotationPolicy(policy PersistentVolumeAnnotationPolicy) PVCReclaimPopulatorOption {
+	return func(p *PVCReclaimPopulator) {
+		p.pvAnnotationPolicy = policy
+	}
+}
+
+func NewPVCReclaimPopulator(client client.Client, opts ...PVCReclaimPopulatorOption) *PVCReclaimPopulator {
+	p := &PVCReclaimPopulator{
+		client:             client,
+		pvAnnotationPolicy: DefaultPersistentVolumeAnnotationPolicy(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+//+kubebuilder:rbac:groups=populator.storage.k8s.io,resources=volumepopulators,verbs=get;create
+
+// populatedByReclaim reports whether the dataSourceRef of the PVC is a PVCReclaim
+func populatedByReclaim(pvc *corev1.PersistentVolumeClaim) bool {
+	ref := pvc.Spec.DataSourceRef
+	return ref != nil && ref.APIGroup != nil && *ref.APIGroup == v1alpha1.GroupVersion.Group && ref.Kind == "PVCReclaim"
+}
+
+// Reconcile binds the PV of the PVCReclaim referenced by a pending PVC to it.
+// The PVCReclaim is marked consumed by the PVC before the PV is touched, so it
+// stops reserving the PV, and is deleted once the PVC references the PV.
+func (p *PVCReclaimPopulator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := p.client.Get(ctx, req.NamespacedName, &pvc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !populatedByReclaim(&pvc) || pvc.DeletionTimestamp != nil || pvc.Status.Phase == corev1.ClaimBound {
+		return ctrl.Result{}, nil
+	}
+
+	ref := pvc.Spec.DataSourceRef
+	if ref.Namespace != nil && *ref.Namespace != pvc.Namespace {
+		logger.Info("PVCReclaim in another namespace cannot populate PVC", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "PVCReclaim", fmt.Sprintf("%s/%s", *ref.Namespace, ref.Name))
+		return ctrl.Result{}, nil
+	}
+
+	var pvcReclaim v1alpha1.PVCReclaim
+	if err := p.client.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: ref.Name}, &pvcReclaim); err != nil {
+		if errors.IsNotFound(err) {
+			if pvc.Spec.VolumeName != "" {
+				// the PVCReclaim was consumed and deleted, the binder completes the binding
+				return ctrl.Result{}, nil
+			}
+			logger.Info("PVCReclaim populating PVC not found", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvc.Namespace, ref.Name))
+			return ctrl.Result{RequeueAfter: populatorRetryInterval}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if consumer := pvcReclaim.Status.ConsumedBy; consumer != nil && consumer.UID != pvc.UID {
+		logger.Info("PVCReclaim was already consumed by another PVC", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "consumedBy", consumer.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		return ctrl.Result{}, nil
+	}
+
+	var pv corev1.PersistentVolume
+	if err := p.client.Get(ctx, types.NamespacedName{Name: pvcReclaim.Spec.PersistentVolumeRef.Name}, &pv); err != nil {
+		if errors.IsNotFound(err) {
+			return p.refuse(ctx, &pvcReclaim, &pvc, fmt.Sprintf("PV %s does not exist", pvcReclaim.Spec.PersistentVolumeRef.Name))
+		}
+		return ctrl.Result{}, err
+	}
+
+	if pvcReclaim.Status.ConsumedBy == nil {
+		if !restorable(&pvcReclaim, &pv) {
+			return p.refuse(ctx, &pvcReclaim, &pvc, fmt.Sprintf("PV %s is not in Released phase", pv.Name))
+		}
+		if message := claimCompatible(&pvc.Spec, &pv); message != "" {
+			return p.refuse(ctx, &pvcReclaim, &pvc, message)
+		}
+
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.ConsumedBy = &corev1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+			Namespace:  pvc.Namespace,
+			Name:       pvc.Name,
+			UID:        pvc.UID,
+		}
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
+		pvcReclaim.Status.Reason = ""
+		pvcReclaim.Status.Message = fmt.Sprintf("Binding PV %s to PVC %s/%s populated from the PVCReclaim", pv.Name, pvc.Namespace, pvc.Name)
+		if err := p.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if claimRef := pv.Spec.ClaimRef; claimRef == nil || claimRef.UID != pvc.UID {
+		logger.Info("Binding PV to PVC populated from PVCReclaim", "pv", pv.Name, "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		patch := client.MergeFrom(pv.DeepCopy())
+		removed := bindPersistentVolume(&pv, &pvc, p.pvAnnotationPolicy)
+		if len(removed) > 0 {
+			logger.Info("Removing PV annotations on restore", "pv", pv.Name, "annotations", removed, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+			statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Status.RemovedPersistentVolumeAnnotations = removed
+			if err := p.client.Status().Patch(ctx, &pvcReclaim, statusPatch); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if err := p.client.Patch(ctx, &pv, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if pvc.Spec.VolumeName == "" {
+		patch := client.MergeFrom(pvc.DeepCopy())
+		pvc.Spec.VolumeName = pv.Name
+		if err := p.client.Patch(ctx, &pvc, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoverySuccess
+	pvcReclaim.Status.Message = fmt.Sprintf("PV %s was bound to PVC %s/%s populated from the PVCReclaim", pv.Name, pvc.Namespace, pvc.Name)
+	if err := p.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	// the PVCReclaimController deletes the consumed PVCReclaim
+	return ctrl.Result{}, nil
+}
+
+// handleConsumed deletes a PVCReclaim consumed by a PVC once its PV is bound to
+// the PVC. Until then the populator is binding them and the PVCReclaim leaves
+// the PV alone. pv is nil when the PV no longer exists.
+func (r *PVCReclaimController) handleConsumed(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	consumer := pvcReclaim.Status.ConsumedBy
+	if pv == nil || pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.UID != consumer.UID || pvcReclaim.Status.RecoverStatus != v1alpha1.RecoverySuccess {
+		return ctrl.Result{}, nil
+	}
+	log.FromContext(ctx).Info("Deleting PVCReclaim consumed by PVC", "pvc", fmt.Sprintf("%s/%s", consumer.Namespace, consumer.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	return ctrl.Result{}, r.deletePVCReclaim(ctx, pvcReclaim)
+}
+
+// refuse records on the PVCReclaim why it cannot populate the PVC and retries later
+func (p *PVCReclaimPopulator) refuse(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pvc *corev1.PersistentVolumeClaim, reason string) (ctrl.Result, error) {
+	reason = fmt.Sprintf("PVC %s/%s cannot be populated from the PVCReclaim: %s", pvc.Namespace, pvc.Name, reason)
+	if pvcReclaim.Status.Reason != reason {
+		log.FromContext(ctx).Info("PVC cannot be populated from PVCReclaim", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "reason", reason, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = reason
+		if err := p.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: populatorRetryInterval}, nil
+}
+
+// registerVolumePopulator registers PVCReclaim as a volume populator, so the
+// volume data source validator accepts PVCs referencing it. Clusters without
+// the validator are left alone.
+func (p *PVCReclaimPopulator) registerVolumePopulator(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	populator := &unstructured.Unstructured{}
+	populator.SetGroupVersionKind(volumePopulatorGVK)
+	populator.SetName(volumePopulatorName)
+	populator.Object["sourceKind"] = map[string]interface{}{
+		"group": v1alpha1.GroupVersion.Group,
+		"kind":  "PVCReclaim",
+	}
+	if err := p.client.Create(ctx, populator); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return nil
+		}
+		if meta.IsNoMatchError(err) {
+			logger.Info("VolumePopulators are not served by the cluster, skipping registration")
+			return nil
+		}
+		return err
+	}
+	logger.Info("Registered PVCReclaim as volume populator", "volumePopulator", volumePopulatorName)
+	return nil
+}
+
+// SetupWithManager sets up the populator with the Manager and registers it as a volume populator when the Manager starts.
+func (p *PVCReclaimPopulator) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.Add(manager.RunnableFunc(p.registerVolumePopulator)); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pvcreclaim-populator").
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			pvc, ok := object.(*corev1.PersistentVolumeClaim)
+			return ok && populatedByReclaim(pvc)
+		}))).
+		Complete(p)
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newPopulatedPVC() *corev1.PersistentVolumeClaim {
+	storageClassName := "standard"
+	apiGroup := v1alpha1.GroupVersion.Group
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restored-db",
+			Namespace: "default",
+			UID:       types.UID("restored-pvc-uid"),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			},
+			DataSourceRef: &corev1.TypedObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "PVCReclaim",
+				Name:     "test-reclaim",
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimPending,
+		},
+	}
+}
+
+func TestPVCReclaimPopulator_Reconcile(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	pv.Annotations = map[string]string{"pv.kubernetes.io/bound-by-controller": "yes"}
+	pvc := newPopulatedPVC()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, pvc).WithObjects(reclaim, pv, pvc).Build()
+	populator := NewPVCReclaimPopulator(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "restored-db", Namespace: "default"}}
+	_, err := populator.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "restored-db", updatedPV.Spec.ClaimRef.Name)
+	assert.Equal(t, types.UID("restored-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+	assert.NotContains(t, updatedPV.Annotations, "pv.kubernetes.io/bound-by-controller")
+
+	var updatedPVC corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC))
+	assert.Equal(t, "test-pv", updatedPVC.Spec.VolumeName)
+
+	reclaimReq := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), reclaimReq.NamespacedName, &updatedReclaim))
+	assert.Equal(t, "restored-db", updatedReclaim.Status.ConsumedBy.Name)
+	assert.Equal(t, v1alpha1.RecoverySuccess, updatedReclaim.Status.RecoverStatus)
+
+	// the consumed PVCReclaim is deleted without re-asserting its claimRef
+	controller := NewPVCReclaimController(fakeClient)
+	_, err = controller.Reconcile(context.Background(), reclaimReq)
+	assert.NoError(t, err)
+	err = fakeClient.Get(context.Background(), reclaimReq.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "restored-db", updatedPV.Spec.ClaimRef.Name)
+}
+
+func TestPVCReclaimPopulator_Reconcile_Refused(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	pvc := newPopulatedPVC()
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("20Gi")
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, pvc).WithObjects(reclaim, pv, pvc).Build()
+	populator := NewPVCReclaimPopulator(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "restored-db", Namespace: "default"}}
+	result, err := populator.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, populatorRetryInterval, result.RequeueAfter)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "default"}, &updatedReclaim))
+	assert.Nil(t, updatedReclaim.Status.ConsumedBy)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Contains(t, updatedReclaim.Status.Reason, "PV capacity 10Gi is less than the PVC request 20Gi")
+
+	var updatedPVC corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC))
+	assert.Empty(t, updatedPVC.Spec.VolumeName)
+}
+
+func TestPVCReclaimPopulator_Reconcile_AlreadyConsumed(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Status.ConsumedBy = &corev1.ObjectReference{Name: "other", UID: types.UID("other-uid")}
+	pvc := newPopulatedPVC()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, pvc).WithObjects(reclaim, pv, pvc).Build()
+	populator := NewPVCReclaimPopulator(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "restored-db", Namespace: "default"}}
+	_, err := populator.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+}
+
+func TestPVCReclaimPopulator_RegisterVolumePopulator(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().Build()
+	populator := NewPVCReclaimPopulator(fakeClient)
+
+	assert.NoError(t, populator.registerVolumePopulator(context.Background()))
+	// registering again is a no-op
+	assert.NoError(t, populator.registerVolumePopulator(context.Background()))
+
+	registered := &unstructured.Unstructured{}
+	registered.SetGroupVersionKind(volumePopulatorGVK)
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: volumePopulatorName}, registered))
+	kind, _, _ := unstructured.NestedString(registered.Object, "sourceKind", "kind")
+	assert.Equal(t, "PVCReclaim", kind)
+}
@@ -0,0 +1,385 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=``,resources=nodes;resourcequotas;limitranges,verbs=get;list;watch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses;volumeattachments,verbs=get;list;watch
+
+// PreflightCheck verifies a precondition of a restore before the controller
+// writes anything. Check returns a message describing why the restore cannot
+// proceed, or an empty message when it can; an error is only returned when the
+// check itself could not be performed.
+type PreflightCheck interface {
+	Name() string
+	Check(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error)
+}
+
+// PreflightCheckFunc is the function implementing a PreflightCheck
+type PreflightCheckFunc func(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error)
+
+type preflightCheck struct {
+	name  string
+	check PreflightCheckFunc
+}
+
+func (p *preflightCheck) Name() string {
+	return p.name
+}
+
+func (p *preflightCheck) Check(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	return p.check(ctx, c, pvcReclaim, pv)
+}
+
+// NewPreflightCheck returns a PreflightCheck with the name running the function
+func NewPreflightCheck(name string, check PreflightCheckFunc) PreflightCheck {
+	return &preflightCheck{
+		name:  name,
+		check: check,
+	}
+}
+
+// DefaultPreflightChecks returns the checks run before a restore unless others are configured
+func DefaultPreflightChecks() []PreflightCheck {
+	return []PreflightCheck{
+		NewPreflightCheck("StorageClass", checkStorageClass),
+		NewPreflightCheck("Namespace", checkNamespace),
+		NewPreflightCheck("ResourceQuota", checkResourceQuota),
+		NewPreflightCheck("LimitRange", checkLimitRange),
+		NewPreflightCheck("Compatibility", checkCompatibility),
+		NewPreflightCheck("NodeAffinity", checkNodeAffinity),
+		NewPreflightCheck("VolumeAttachment", checkVolumeAttachment),
+		NewPreflightCheck("RestoreOverrides", checkRestoreOverrides),
+	}
+}
+
+// migrationPreflightChecks returns the checks run before the PV is bound to a
+// staging PVC, the others only apply to a PVC restored in place
+func migrationPreflightChecks() []PreflightCheck {
+	return []PreflightCheck{
+		NewPreflightCheck("StorageClass", checkStorageClass),
+		NewPreflightCheck("Namespace", checkNamespace),
+		NewPreflightCheck("Compatibility", checkCompatibility),
+	}
+}
+
+// runPreflightChecks runs the preflight checks against the PV and records the
+// results in status. It returns the messages of the checks which failed.
+func (r *PVCReclaimController) runPreflightChecks(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) ([]string, error) {
+	return r.runChecks(ctx, pvcReclaim, pv, r.preflightChecks)
+}
+
+// runChecks runs the checks against the PV the way runPreflightChecks does
+func (r *PVCReclaimController) runChecks(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, checks []PreflightCheck) ([]string, error) {
+	var failures []string
+	results := make([]v1alpha1.PreflightCheckResult, 0, len(checks))
+	for _, check := range checks {
+		message, err := check.Check(ctx, r.client, pvcReclaim, pv)
+		if err != nil {
+			return nil, fmt.Errorf("preflight check %s: %w", check.Name(), err)
+		}
+		results = append(results, v1alpha1.PreflightCheckResult{
+			Name:    check.Name(),
+			Passed:  message == "",
+			Message: message,
+		})
+		if message != "" {
+			failures = append(failures, fmt.Sprintf("%s: %s", check.Name(), message))
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionPreflightPassed,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             "AllChecksPassed",
+		Message:            fmt.Sprintf("%d preflight checks passed", len(results)),
+	}
+	if len(failures) > 0 {
+		log.FromContext(ctx).Info("Preflight checks failed", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), "failures", failures)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ChecksFailed"
+		condition.Message = strings.Join(failures, "; ")
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.PreflightChecks = results
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, condition)
+	if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// claimStorageRequest returns the storage requested by the restored PVC, the
+// override of the PVCReclaim if it has one
+func claimStorageRequest(pvcReclaim *v1alpha1.PVCReclaim) (resource.Quantity, bool) {
+	if overrides := pvcReclaim.Spec.RestoreOverrides; overrides != nil && overrides.StorageRequest != nil {
+		return *overrides.StorageRequest, true
+	}
+	request, found := pvcReclaim.Spec.PersistentVolumeClaimSpec.Resources.Requests[corev1.ResourceStorage]
+	return request, found
+}
+
+// checkStorageClass verifies the StorageClass of the PVC still exists
+func checkStorageClass(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	storageClassName := pvcReclaim.Spec.PersistentVolumeClaimSpec.StorageClassName
+	if storageClassName == nil || *storageClassName == "" {
+		return "", nil
+	}
+	var storageClass storagev1.StorageClass
+	if err := c.Get(ctx, types.NamespacedName{Name: *storageClassName}, &storageClass); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("StorageClass %s does not exist", *storageClassName), nil
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// checkNamespace verifies the namespace of the PVC exists and is not being deleted
+func checkNamespace(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	var namespace corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: pvcReclaim.Namespace}, &namespace); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("namespace %s does not exist", pvcReclaim.Namespace), nil
+		}
+		return "", err
+	}
+	if namespace.DeletionTimestamp != nil {
+		return fmt.Sprintf("namespace %s is terminating", pvcReclaim.Namespace), nil
+	}
+	return "", nil
+}
+
+// checkResourceQuota verifies the ResourceQuotas of the namespace leave room for the PVC
+func checkResourceQuota(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	var quotas corev1.ResourceQuotaList
+	if err := c.List(ctx, &quotas, client.InNamespace(pvcReclaim.Namespace)); err != nil {
+		return "", err
+	}
+
+	wanted := corev1.ResourceList{
+		corev1.ResourcePersistentVolumeClaims: resource.MustParse("1"),
+	}
+	request, found := claimStorageRequest(pvcReclaim)
+	if found {
+		wanted[corev1.ResourceRequestsStorage] = request
+	}
+	if storageClassName := pvcReclaim.Spec.PersistentVolumeClaimSpec.StorageClassName; storageClassName != nil && *storageClassName != "" {
+		prefix := *storageClassName + ".storageclass.storage.k8s.io/"
+		wanted[corev1.ResourceName(prefix+string(corev1.ResourcePersistentVolumeClaims))] = resource.MustParse("1")
+		if found {
+			wanted[corev1.ResourceName(prefix+string(corev1.ResourceRequestsStorage))] = request
+		}
+	}
+
+	for _, quota := range quotas.Items {
+		for name, amount := range wanted {
+			hard, found := quota.Spec.Hard[name]
+			if !found {
+				continue
+			}
+			used := quota.Status.Used[name].DeepCopy()
+			used.Add(amount)
+			if used.Cmp(hard) > 0 {
+				current := quota.Status.Used[name]
+				return fmt.Sprintf("ResourceQuota %s would be exceeded for %s, used %s plus %s is over %s", quota.Name, name, current.String(), amount.String(), hard.String()), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// checkLimitRange verifies the storage request of the PVC is within the LimitRanges of the namespace
+func checkLimitRange(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	request, found := claimStorageRequest(pvcReclaim)
+	if !found {
+		return "", nil
+	}
+	var limitRanges corev1.LimitRangeList
+	if err := c.List(ctx, &limitRanges, client.InNamespace(pvcReclaim.Namespace)); err != nil {
+		return "", err
+	}
+
+	for _, limitRange := range limitRanges.Items {
+		for _, limit := range limitRange.Spec.Limits {
+			if limit.Type != corev1.LimitTypePersistentVolumeClaim {
+				continue
+			}
+			if minimum, found := limit.Min[corev1.ResourceStorage]; found && request.Cmp(minimum) < 0 {
+				return fmt.Sprintf("LimitRange %s requires at least %s of storage, PVC requests %s", limitRange.Name, minimum.String(), request.String()), nil
+			}
+			if maximum, found := limit.Max[corev1.ResourceStorage]; found && request.Cmp(maximum) > 0 {
+				return fmt.Sprintf("LimitRange %s allows at most %s of storage, PVC requests %s", limitRange.Name, maximum.String(), request.String()), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// checkCompatibility verifies the PVC can bind to the PV given their storage
+// class, access modes, volume mode and capacity
+func checkCompatibility(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	// a larger request override is validated by checkRestoreOverrides
+	return claimCompatible(&pvcReclaim.Spec.PersistentVolumeClaimSpec, pv), nil
+}
+
+// claimCompatible returns why the PV cannot be bound to a PVC with the spec, "" if it can
+func claimCompatible(spec *corev1.PersistentVolumeClaimSpec, pv *corev1.PersistentVolume) string {
+	if spec.StorageClassName != nil && *spec.StorageClassName != pv.Spec.StorageClassName {
+		return fmt.Sprintf("PVC storageClassName %q does not match PV storageClassName %q", *spec.StorageClassName, pv.Spec.StorageClassName)
+	}
+	for _, accessMode := range spec.AccessModes {
+		if !slices.Contains(pv.Spec.AccessModes, accessMode) {
+			return fmt.Sprintf("PV %s does not support access mode %s", pv.Name, accessMode)
+		}
+	}
+
+	claimMode, volumeMode := corev1.PersistentVolumeFilesystem, corev1.PersistentVolumeFilesystem
+	if spec.VolumeMode != nil {
+		claimMode = *spec.VolumeMode
+	}
+	if pv.Spec.VolumeMode != nil {
+		volumeMode = *pv.Spec.VolumeMode
+	}
+	if claimMode != volumeMode {
+		return fmt.Sprintf("PVC volumeMode %s does not match PV volumeMode %s", claimMode, volumeMode)
+	}
+
+	request, found := spec.Resources.Requests[corev1.ResourceStorage]
+	capacity, hasCapacity := pv.Spec.Capacity[corev1.ResourceStorage]
+	if found && hasCapacity && capacity.Cmp(request) < 0 {
+		return fmt.Sprintf("PV capacity %s is less than the PVC request %s", capacity.String(), request.String())
+	}
+	return ""
+}
+
+// checkNodeAffinity verifies a Ready node matches the node affinity of a local PV
+func checkNodeAffinity(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	if pv.Spec.Local == nil || pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return "", nil
+	}
+	var nodes corev1.NodeList
+	if err := c.List(ctx, &nodes); err != nil {
+		return "", err
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if nodeReady(node) && nodeSelectorMatches(pv.Spec.NodeAffinity.Required, node) {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("no Ready node matches the node affinity of local PV %s", pv.Name), nil
+}
+
+// nodeReady reports whether the node has the Ready condition
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeSelectorMatches reports whether any term of the node selector matches the node
+func nodeSelectorMatches(selector *corev1.NodeSelector, node *corev1.Node) bool {
+	for _, term := range selector.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		matches := true
+		for _, requirement := range term.MatchExpressions {
+			value, found := node.Labels[requirement.Key]
+			matches = matches && nodeSelectorRequirementMatches(requirement, value, found)
+		}
+		for _, requirement := range term.MatchFields {
+			found := requirement.Key == metav1.ObjectNameField
+			matches = matches && nodeSelectorRequirementMatches(requirement, node.Name, found)
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSelectorRequirementMatches evaluates the requirement against the value of a node label or field
+func nodeSelectorRequirementMatches(requirement corev1.NodeSelectorRequirement, value string, found bool) bool {
+	switch requirement.Operator {
+	case corev1.NodeSelectorOpIn:
+		return found && slices.Contains(requirement.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !found || !slices.Contains(requirement.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return found
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !found
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !found || len(requirement.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		wanted, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == corev1.NodeSelectorOpGt {
+			return actual > wanted
+		}
+		return actual < wanted
+	}
+	return false
+}
+
+// checkVolumeAttachment verifies the PV is no longer attached to any node
+func checkVolumeAttachment(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	var attachments storagev1.VolumeAttachmentList
+	if err := c.List(ctx, &attachments); err != nil {
+		return "", err
+	}
+	for _, attachment := range attachments.Items {
+		source := attachment.Spec.Source.PersistentVolumeName
+		if source != nil && *source == pv.Name {
+			return fmt.Sprintf("VolumeAttachment %s still attaches PV %s to node %s", attachment.Name, pv.Name, attachment.Spec.NodeName), nil
+		}
+	}
+	return "", nil
+}
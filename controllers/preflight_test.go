@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newPreflightNamespace() *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+}
+
+func TestPVCReclaimController_Reconcile_PreflightFailed(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	pvName := pv.Name
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage", Namespace: "default"},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{corev1.ResourceRequestsStorage: resource.MustParse("15Gi")},
+		},
+		Status: corev1.ResourceQuotaStatus{
+			Used: corev1.ResourceList{corev1.ResourceRequestsStorage: resource.MustParse("10Gi")},
+		},
+	}
+	attachment := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: "csi-1234"},
+		Spec: storagev1.VolumeAttachmentSpec{
+			NodeName: "node-1",
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(newPreflightNamespace(), reclaim, pv, quota, attachment).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.True(t, meta.IsStatusConditionFalse(updatedReclaim.Status.Conditions, v1alpha1.ConditionPreflightPassed))
+
+	results := make(map[string]v1alpha1.PreflightCheckResult)
+	for _, result := range updatedReclaim.Status.PreflightChecks {
+		results[result.Name] = result
+	}
+	assert.Len(t, results, len(DefaultPreflightChecks()))
+	assert.False(t, results["StorageClass"].Passed)
+	assert.False(t, results["ResourceQuota"].Passed)
+	assert.False(t, results["VolumeAttachment"].Passed)
+	assert.Contains(t, results["VolumeAttachment"].Message, "node-1")
+	assert.True(t, results["Namespace"].Passed)
+	assert.True(t, results["Compatibility"].Passed)
+
+	// nothing was written for the restore
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &corev1.PersistentVolumeClaim{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_CustomPreflightCheck(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, &corev1.PersistentVolumeClaim{}).WithObjects(reclaim, pv).Build()
+	check := NewPreflightCheck("Custom", func(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+		return "", nil
+	})
+	controller := NewPVCReclaimController(fakeClient, WithPreflightChecks(check))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &v1alpha1.PVCReclaim{})
+	assert.True(t, errors.IsNotFound(err))
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &corev1.PersistentVolumeClaim{}))
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+
+	message, err := checkCompatibility(context.Background(), nil, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Empty(t, message)
+
+	reclaim.Spec.PersistentVolumeClaimSpec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	message, _ = checkCompatibility(context.Background(), nil, reclaim, pv)
+	assert.Contains(t, message, "ReadWriteMany")
+
+	reclaim.Spec.PersistentVolumeClaimSpec.AccessModes = nil
+	reclaim.Spec.PersistentVolumeClaimSpec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("20Gi")
+	message, _ = checkCompatibility(context.Background(), nil, reclaim, pv)
+	assert.Contains(t, message, "capacity")
+}
+
+func TestCheckNodeAffinity(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = corev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	pv.Spec.Local = &corev1.LocalVolumeSource{Path: "/mnt/disks/vol1"}
+	pv.Spec.NodeAffinity = &corev1.VolumeNodeAffinity{
+		Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      "kubernetes.io/hostname",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{"node-1"},
+				}},
+			}},
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: map[string]string{"kubernetes.io/hostname": "node-1"},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(node).Build()
+
+	message, err := checkNodeAffinity(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "no Ready node")
+
+	node.Status.Conditions[0].Status = corev1.ConditionTrue
+	assert.NoError(t, fakeClient.Status().Update(context.Background(), node))
+	message, err = checkNodeAffinity(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Empty(t, message)
+}
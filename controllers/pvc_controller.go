@@ -26,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -34,15 +35,31 @@ import (
 
 // PVCController reconciles a PersistentVolumeClaim object
 type PVCController struct {
-	client client.Client
+	client    client.Client
+	sanitizer *Sanitizer
 }
 
 var _ reconcile.Reconciler = &PVCController{}
 
-func NewPVCController(client client.Client) *PVCController {
-	return &PVCController{
-		client: client,
+// PVCControllerOption configures optional behavior of the PVCController
+type PVCControllerOption func(*PVCController)
+
+// WithClaimSanitizer sets the rules stripping PVC metadata and spec fields on capture, DefaultSanitizer by default
+func WithClaimSanitizer(sanitizer *Sanitizer) PVCControllerOption {
+	return func(r *PVCController) {
+		r.sanitizer = sanitizer
+	}
+}
+
+func NewPVCController(client client.Client, opts ...PVCControllerOption) *PVCController {
+	r := &PVCController{
+		client:    client,
+		sanitizer: DefaultSanitizer(),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
 //+kubebuilder:rbac:groups=``,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
@@ -62,6 +79,18 @@ func (r *PVCController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if transientClaim(&pvc) {
+		// staging PVCs of a migration and scratch PVCs of a preview are not reclaimed
+		return ctrl.Result{}, nil
+	}
+
+	if pvc.DeletionTimestamp != nil {
+		if controllerutil.ContainsFinalizer(&pvc, snapshotFinalizer) {
+			return r.snapshotOnRelease(ctx, &pvc)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	if pvc.Spec.VolumeName == "" || pvc.Status.Phase != corev1.ClaimBound {
 		logger.Info("PVC is not Bound, nothing to be done", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
 		return ctrl.Result{}, nil
@@ -72,8 +101,8 @@ func (r *PVCController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	pvcReclaim.Annotations = pvc.Annotations
-	for labelKey, labelVal := range pvc.Labels {
+	pvcReclaim.Annotations = r.sanitizer.Annotations(pvc.Annotations)
+	for labelKey, labelVal := range r.sanitizer.Labels(pvc.Labels) {
 		pvcReclaim.Labels[labelKey] = labelVal
 	}
 	pvcReclaim.Spec.Restore = false
@@ -85,11 +114,18 @@ func (r *PVCController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	patch := client.MergeFrom(pvcReclaim.DeepCopy())
 	pvcReclaim.Status.Reason = ""
 	pvcReclaim.Status.RecoverStatus = v1alpha1.NotRecovered
+	pvcReclaim.Status.ClaimUID = pvc.UID
+	pvcReclaim.Status.ConsumedBy = nil
 	pvcReclaim.Status.Message = fmt.Sprintf("PVC %s Bound, PVCReclaim %s created for recovery", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
 	if err := r.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
 		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+
+	var pv corev1.PersistentVolume
+	if err := r.client.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.ensureSnapshotFinalizer(ctx, &pvc, &pv)
 }
 
 func (r *PVCController) getOrCreateClaimRef(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (v1alpha1.PVCReclaim, error) {
@@ -118,18 +154,17 @@ func (r *PVCController) getOrCreateClaimRef(ctx context.Context, pvc *corev1.Per
 					Kind:       pv.Kind,
 					APIVersion: pv.APIVersion,
 					Name:       pv.Name,
+					UID:        pv.UID,
 				},
-				PersistentVolumeClaimSpec: pvc.Spec,
+				PersistentVolumeClaimSpec: r.sanitizer.ClaimSpec(&pvc.Spec),
+				PersistentVolumeSpec:      sanitizedPersistentVolumeSpec(&pv),
 				Restore:                   false,
 			},
 		}
-		if pvc.Annotations != nil {
-			pvcReclaim.Annotations = pvc.Annotations
-		}
-		if pvc.Labels == nil {
+		pvcReclaim.Annotations = r.sanitizer.Annotations(pvc.Annotations)
+		pvcReclaim.Labels = r.sanitizer.Labels(pvc.Labels)
+		if pvcReclaim.Labels == nil {
 			pvcReclaim.Labels = make(map[string]string)
-		} else {
-			pvcReclaim.Labels = pvc.Labels
 		}
 		pvcReclaim.Labels[reclaimPVLabel] = pv.Name
 		if err := r.client.Create(ctx, &pvcReclaim); err != nil {
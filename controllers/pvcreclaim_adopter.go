@@ -0,0 +1,249 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// PVCReclaimAdopter rebuilds PVCReclaim objects which went missing, e.g. after
+// the CRD was re-installed or the namespace of the PVC was deleted, from the
+// claim records mirrored onto the PVs they reserved. Released PVs which predate
+// the controller get a PVCReclaim reconstructed from the PV fields.
+type PVCReclaimAdopter struct {
+	client   client.Client
+	interval time.Duration
+}
+
+var _ manager.LeaderElectionRunnable = &PVCReclaimAdopter{}
+
+// PVCReclaimAdopterOption configures optional behavior of the PVCReclaimAdopter
+type PVCReclaimAdopterOption func(*PVCReclaimAdopter)
+
+// WithAdoptionInterval repeats the adoption sweep at the interval, a zero interval only sweeps on startup
+func WithAdoptionInterval(interval time.Duration) PVCReclaimAdopterOption {
+	return func(a *PVCReclaimAdopter) {
+		a.interval = interval
+	}
+}
+
+func NewPVCReclaimAdopter(client client.Client, opts ...PVCReclaimAdopterOption) *PVCReclaimAdopter {
+	a := &PVCReclaimAdopter{
+		client: client,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Start runs an adoption sweep once the caches of the manager are synced and
+// then at every interval until the manager stops
+func (a *PVCReclaimAdopter) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	if err := a.adopt(ctx); err != nil {
+		logger.Error(err, "Failed to adopt PVs without PVCReclaim")
+	}
+	if a.interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := a.adopt(ctx); err != nil {
+				logger.Error(err, "Failed to adopt PVs without PVCReclaim")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leader adopts PVs
+func (a *PVCReclaimAdopter) NeedLeaderElection() bool {
+	return true
+}
+
+// adopt creates a PVCReclaim for every PV which is not Bound and has no
+// PVCReclaim, from the claim record of the PV if it has one or else, for a
+// Released PV, from its claimRef and the PV fields. PVs abandoned by a confirmed
+// deletion of their PVCReclaim are skipped. A PV which cannot be adopted does
+// not stop the sweep, the errors are returned once every PV was tried.
+func (a *PVCReclaimAdopter) adopt(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	var pvs corev1.PersistentVolumeList
+	if err := a.client.List(ctx, &pvs); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if pv.Status.Phase == corev1.VolumeBound || pv.DeletionTimestamp != nil {
+			continue
+		}
+
+		var record *claimRecord
+		reconstructed := false
+		if encoded, found := pv.Annotations[claimRecordAnnotation]; found {
+			var err error
+			if record, err = decodeClaimRecord(encoded); err != nil {
+				logger.Error(err, "Failed to decode claim record", "pv", pv.Name)
+				continue
+			}
+		} else if pv.Status.Phase == corev1.VolumeReleased && pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.Namespace != "" && pv.Spec.ClaimRef.Name != "" {
+			record = claimRecordFromPersistentVolume(pv)
+			reconstructed = true
+		} else {
+			continue
+		}
+
+		claimRef := pv.Spec.ClaimRef
+		if claimRef != nil && (claimRef.Namespace != record.Namespace || claimRef.Name != record.Name) {
+			// the PV has been claimed by another PVC since the record was written
+			continue
+		}
+		if pv.Annotations[abandonedAnnotation] == fmt.Sprintf("%s/%s", record.Namespace, record.Name) {
+			// the PVCReclaim of the claim was deleted on purpose
+			continue
+		}
+
+		var pvcReclaim v1alpha1.PVCReclaim
+		err := a.client.Get(ctx, types.NamespacedName{Namespace: record.Namespace, Name: record.Name}, &pvcReclaim)
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to get PVCReclaim of claim record", "pv", pv.Name, "PVCReclaim", fmt.Sprintf("%s/%s", record.Namespace, record.Name))
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := a.adoptFromClaimRecord(ctx, pv, record, reconstructed); err != nil {
+			if errors.IsAlreadyExists(err) {
+				continue
+			}
+			if errors.IsNotFound(err) {
+				logger.Info("Namespace of claim does not exist, skipping PV", "pv", pv.Name, "namespace", record.Namespace)
+				continue
+			}
+			logger.Error(err, "Failed to adopt PV from claim record", "pv", pv.Name, "PVCReclaim", fmt.Sprintf("%s/%s", record.Namespace, record.Name))
+			errs = append(errs, err)
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// claimRecordFromPersistentVolume reconstructs the claim record of the PVC a
+// Released PV was bound to from its claimRef and the PV fields. Labels,
+// annotations and any PVC spec field not reflected on the PV are lost.
+func claimRecordFromPersistentVolume(pv *corev1.PersistentVolume) *claimRecord {
+	storageClassName := pv.Spec.StorageClassName
+	record := &claimRecord{
+		Namespace: pv.Spec.ClaimRef.Namespace,
+		Name:      pv.Spec.ClaimRef.Name,
+		UID:       pv.Spec.ClaimRef.UID,
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pv.Spec.AccessModes,
+			StorageClassName: &storageClassName,
+			VolumeMode:       pv.Spec.VolumeMode,
+			VolumeName:       pv.Name,
+		},
+	}
+	if capacity, found := pv.Spec.Capacity[corev1.ResourceStorage]; found {
+		record.Spec.Resources.Requests = corev1.ResourceList{
+			corev1.ResourceStorage: capacity,
+		}
+	}
+	return record
+}
+
+// adoptFromClaimRecord creates the PVCReclaim described by the claim record of
+// the PV, reconstructed is set when the record was derived from the PV fields
+func (a *PVCReclaimAdopter) adoptFromClaimRecord(ctx context.Context, pv *corev1.PersistentVolume, record *claimRecord, reconstructed bool) error {
+	pvcReclaim := v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        record.Name,
+			Namespace:   record.Namespace,
+			Labels:      make(map[string]string, len(record.Labels)+1),
+			Annotations: record.Annotations,
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       pv.Name,
+				UID:        pv.UID,
+			},
+			PersistentVolumeClaimSpec: record.Spec,
+			PersistentVolumeSpec:      sanitizedPersistentVolumeSpec(pv),
+		},
+	}
+	for labelKey, labelVal := range record.Labels {
+		pvcReclaim.Labels[labelKey] = labelVal
+	}
+	pvcReclaim.Labels[reclaimPVLabel] = pv.Name
+
+	reason := "ClaimRecord"
+	message := fmt.Sprintf("Rebuilt from the %s annotation of PV %s", claimRecordAnnotation, pv.Name)
+	if reconstructed {
+		reason = "ReconstructedFromPersistentVolume"
+		message = fmt.Sprintf("PVC spec reconstructed from the fields of Released PV %s, labels, annotations and other PVC settings of the original PVC are not known", pv.Name)
+	}
+
+	log.FromContext(ctx).Info("Adopting PV without PVCReclaim", "pv", pv.Name, "reason", reason, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := a.client.Create(ctx, &pvcReclaim); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.RecoverStatus = v1alpha1.NotRecovered
+	pvcReclaim.Status.ClaimUID = record.UID
+	pvcReclaim.Status.Message = fmt.Sprintf("PVCReclaim adopted PV %s", pv.Name)
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionAdopted,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	return a.client.Status().Patch(ctx, &pvcReclaim, patch)
+}
+
+// SetupWithManager registers the adopter to run when the Manager starts.
+func (a *PVCReclaimAdopter) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(a)
+}
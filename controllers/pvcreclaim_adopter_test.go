@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimAdopter_AdoptsFromClaimRecord(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Labels["app"] = "db"
+	reclaim.Annotations = map[string]string{"owner": "team-a"}
+	encoded, err := encodeClaimRecord(newClaimRecord(reclaim))
+	assert.NoError(t, err)
+	pv.Annotations = map[string]string{claimRecordAnnotation: encoded}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.PVCReclaim{}, pv).WithObjects(pv).Build()
+	adopter := NewPVCReclaimAdopter(fakeClient)
+	assert.NoError(t, adopter.Start(context.Background()))
+
+	var adopted v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "default"}, &adopted))
+	assert.Equal(t, "test-pv", adopted.Spec.PersistentVolumeRef.Name)
+	assert.Equal(t, reclaim.Spec.PersistentVolumeClaimSpec, adopted.Spec.PersistentVolumeClaimSpec)
+	assert.Equal(t, map[string]string{"app": "db", reclaimPVLabel: "test-pv"}, adopted.Labels)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, adopted.Annotations)
+	assert.Equal(t, types.UID("old-pvc-uid"), adopted.Status.ClaimUID)
+	assert.True(t, meta.IsStatusConditionTrue(adopted.Status.Conditions, v1alpha1.ConditionAdopted))
+}
+
+func TestPVCReclaimAdopter_SkipsPVClaimedByAnotherPVC(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	encoded, err := encodeClaimRecord(newClaimRecord(reclaim))
+	assert.NoError(t, err)
+	pv.Annotations = map[string]string{claimRecordAnnotation: encoded}
+	pv.Spec.ClaimRef.Name = "other-pvc"
+
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.PVCReclaim{}, pv).WithObjects(pv).Build()
+	adopter := NewPVCReclaimAdopter(fakeClient)
+	assert.NoError(t, adopter.Start(context.Background()))
+
+	var adopted v1alpha1.PVCReclaim
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "default"}, &adopted)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimAdopter_ReconstructsFromReleasedPV(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	volumeMode := corev1.PersistentVolumeFilesystem
+	_, pv := newReleasedFixtures()
+	pv.Spec.VolumeMode = &volumeMode
+	available := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "available-pv"},
+		Status:     corev1.PersistentVolumeStatus{Phase: corev1.VolumeAvailable},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.PVCReclaim{}, pv, available).WithObjects(pv, available).Build()
+	adopter := NewPVCReclaimAdopter(fakeClient)
+	assert.NoError(t, adopter.Start(context.Background()))
+
+	var adopted v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "default"}, &adopted))
+	assert.Equal(t, "test-pv", adopted.Spec.PersistentVolumeClaimSpec.VolumeName)
+	assert.Equal(t, "standard", *adopted.Spec.PersistentVolumeClaimSpec.StorageClassName)
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, adopted.Spec.PersistentVolumeClaimSpec.AccessModes)
+	assert.Equal(t, &volumeMode, adopted.Spec.PersistentVolumeClaimSpec.VolumeMode)
+	assert.True(t, resource.MustParse("10Gi").Equal(adopted.Spec.PersistentVolumeClaimSpec.Resources.Requests[corev1.ResourceStorage]))
+	assert.Equal(t, types.UID("old-pvc-uid"), adopted.Status.ClaimUID)
+	condition := meta.FindStatusCondition(adopted.Status.Conditions, v1alpha1.ConditionAdopted)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, "ReconstructedFromPersistentVolume", condition.Reason)
+	}
+
+	var reclaims v1alpha1.PVCReclaimList
+	assert.NoError(t, fakeClient.List(context.Background(), &reclaims))
+	assert.Len(t, reclaims.Items, 1)
+}
+
+func TestPVCReclaimAdopter_ContinuesAfterFailedAdoption(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	_, pv := newReleasedFixtures()
+	restricted := pv.DeepCopy()
+	restricted.Name = "a-restricted-pv"
+	restricted.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "restricted", Name: "restricted-pvc", UID: types.UID("restricted-pvc-uid")}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.PVCReclaim{}, pv, restricted).WithObjects(restricted, pv).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if obj.GetNamespace() == "restricted" {
+					return errors.NewForbidden(v1alpha1.GroupVersion.WithResource("pvcreclaims").GroupResource(), obj.GetName(), nil)
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+	adopter := NewPVCReclaimAdopter(fakeClient)
+	err := adopter.adopt(context.Background())
+	assert.ErrorContains(t, err, `pvcreclaims.yibozhuang.me "restricted-pvc" is forbidden`)
+
+	var adopted v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-reclaim", Namespace: "default"}, &adopted))
+	assert.Equal(t, "test-pv", adopted.Spec.PersistentVolumeRef.Name)
+}
+
+func TestPVCReclaimAdopter_SkipsPVAfterConfirmedDeletion(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Annotations = map[string]string{confirmDeleteAnnotation: "true"}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.PVCReclaim{}, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Contains(t, updatedPV.Annotations, claimRecordAnnotation)
+
+	// the deletion is confirmed by the annotation
+	assert.NoError(t, fakeClient.Delete(context.Background(), reclaim))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	var deleted v1alpha1.PVCReclaim
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &deleted)
+	assert.True(t, errors.IsNotFound(err))
+
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.NotContains(t, updatedPV.Annotations, claimRecordAnnotation)
+	assert.Equal(t, "default/test-reclaim", updatedPV.Annotations[abandonedAnnotation])
+
+	adopter := NewPVCReclaimAdopter(fakeClient)
+	assert.NoError(t, adopter.adopt(context.Background()))
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &deleted)
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -0,0 +1,454 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// DefaultArchiveImage is the image with rclone, tar and sha256sum uploading and extracting archives
+	DefaultArchiveImage = "rclone/rclone:1.68"
+	// archiveLabel marks the scratch PVC and the Jobs of an archive, and the PVC it is restored into, with the name of its PVCReclaim
+	archiveLabel = "pvc-reclaim.yibozhuang.me/archive"
+	// archivePollInterval is how often a running upload or restore is checked on
+	archivePollInterval = 10 * time.Second
+)
+
+// archiveScript uploads /data as a gzipped tarball and writes its checksum to
+// the termination message of the container
+const archiveScript = `set -eo pipefail
+mkfifo /tmp/archive
+sha256sum < /tmp/archive > /tmp/sha256 &
+tar -C /data -czf - . | tee /tmp/archive | rclone rcat "archive:$BUCKET/$OBJECT_KEY"
+wait $!
+cut -d' ' -f1 /tmp/sha256 > /dev/termination-log
+`
+
+// unarchiveScript extracts the gzipped tarball into /data and fails when its
+// checksum does not match the one recorded on upload
+const unarchiveScript = `set -eo pipefail
+mkfifo /tmp/archive
+sha256sum < /tmp/archive > /tmp/sha256 &
+rclone cat "archive:$BUCKET/$OBJECT_KEY" | tee /tmp/archive | tar -C /data -xzf -
+wait $!
+sum=$(cut -d' ' -f1 /tmp/sha256)
+if [ "$sum" != "$SHA256" ]; then
+  echo "checksum $sum of the archive does not match $SHA256" | tee /dev/termination-log >&2
+  exit 1
+fi
+`
+
+// archiveObjectKey returns the key of the archive of the PV in the bucket
+func archiveObjectKey(pvcReclaim *v1alpha1.PVCReclaim, pvName string) string {
+	return fmt.Sprintf("%s%s/%s/%s.tar.gz", pvcReclaim.Spec.Archive.Prefix, pvcReclaim.Namespace, pvcReclaim.Name, pvName)
+}
+
+// archived reports whether the contents of the PV are archived
+func archived(pvcReclaim *v1alpha1.PVCReclaim) bool {
+	return pvcReclaim.Status.Archive != nil && pvcReclaim.Status.Archive.Phase == v1alpha1.ArchiveCompleted
+}
+
+// archiveActive reports whether the PV is bound to the scratch PVC of an upload
+func archiveActive(pvcReclaim *v1alpha1.PVCReclaim) bool {
+	status := pvcReclaim.Status.Archive
+	return status != nil && (status.Phase == v1alpha1.ArchiveUploading || status.Phase == v1alpha1.ArchiveReleasing)
+}
+
+// archiveJob returns a Job running the script with rclone configured for the bucket of the archive
+func (r *PVCReclaimController) archiveJob(pvcReclaim *v1alpha1.PVCReclaim, name string, status *v1alpha1.ArchiveStatus, script string, pvc *corev1.PersistentVolumeClaim, readOnly bool) *batchv1.Job {
+	labels := map[string]string{archiveLabel: pvcReclaim.Name}
+	backoffLimit := int32(2)
+	credential := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: status.CredentialsSecretName},
+			Key:                  key,
+		}}
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pvcReclaim.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:                     "archive",
+						Image:                    r.archiveImage,
+						Command:                  []string{"sh", "-c", script},
+						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						Env: []corev1.EnvVar{
+							{Name: "BUCKET", Value: status.Bucket},
+							{Name: "OBJECT_KEY", Value: status.ObjectKey},
+							{Name: "SHA256", Value: status.SHA256},
+							{Name: "RCLONE_CONFIG_ARCHIVE_TYPE", Value: "s3"},
+							{Name: "RCLONE_CONFIG_ARCHIVE_PROVIDER", Value: "Other"},
+							{Name: "RCLONE_CONFIG_ARCHIVE_ENDPOINT", Value: status.Endpoint},
+							{Name: "RCLONE_CONFIG_ARCHIVE_ACCESS_KEY_ID", ValueFrom: credential("AWS_ACCESS_KEY_ID")},
+							{Name: "RCLONE_CONFIG_ARCHIVE_SECRET_ACCESS_KEY", ValueFrom: credential("AWS_SECRET_ACCESS_KEY")},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "data", MountPath: "/data", ReadOnly: readOnly},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name, ReadOnly: readOnly}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// archiveTerminationMessage returns the termination message of the container of the pod of the Job which terminated last
+func (r *PVCReclaimController) archiveTerminationMessage(ctx context.Context, job *batchv1.Job) (string, error) {
+	var pods corev1.PodList
+	if err := r.client.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return "", err
+	}
+	message := ""
+	var finishedAt time.Time
+	for i := range pods.Items {
+		for _, containerStatus := range pods.Items[i].Status.ContainerStatuses {
+			terminated := containerStatus.State.Terminated
+			if terminated == nil || terminated.FinishedAt.Time.Before(finishedAt) {
+				continue
+			}
+			message, finishedAt = strings.TrimSpace(terminated.Message), terminated.FinishedAt.Time
+		}
+	}
+	return message, nil
+}
+
+// syncArchiveStatus forgets a failed archive once spec.archive is removed, so setting it again retries the upload
+func (r *PVCReclaimController) syncArchiveStatus(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) error {
+	if pvcReclaim.Spec.Archive != nil || pvcReclaim.Status.Archive == nil || pvcReclaim.Status.Archive.Phase != v1alpha1.ArchiveFailed {
+		return nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Archive = nil
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// startArchive binds the Released PV to a scratch PVC and creates the Job uploading its contents
+func (r *PVCReclaimController) startArchive(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	name := pvcReclaim.Name + "-archive"
+	status := &v1alpha1.ArchiveStatus{
+		Phase:                 v1alpha1.ArchiveUploading,
+		JobName:               name,
+		Endpoint:              pvcReclaim.Spec.Archive.Endpoint,
+		Bucket:                pvcReclaim.Spec.Archive.Bucket,
+		ObjectKey:             archiveObjectKey(pvcReclaim, pv.Name),
+		CredentialsSecretName: pvcReclaim.Spec.Archive.CredentialsSecretName,
+		Message:               "Upload Job created",
+	}
+	pvc := scratchClaim(name, pvcReclaim.Namespace, map[string]string{archiveLabel: pvcReclaim.Name}, pv)
+	job := r.archiveJob(pvcReclaim, name, status, archiveScript, pvc, true)
+	// the scratch PVC and Job are garbage collected with the PVCReclaim
+	for _, object := range []client.Object{pvc, job} {
+		if err := controllerutil.SetControllerReference(pvcReclaim, object, r.client.Scheme()); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	log.FromContext(ctx).Info("Archiving released PV", "pv", pv.Name, "bucket", status.Bucket, "objectKey", status.ObjectKey, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := r.client.Create(ctx, pvc); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+	patch := client.MergeFrom(pv.DeepCopy())
+	bindPersistentVolume(pv, pvc, PersistentVolumeAnnotationPolicy{})
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Archive = status
+	if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: archivePollInterval}, nil
+}
+
+// archive records the checksum once the upload Job completes, then returns the PV to its claimRef
+func (r *PVCReclaimController) archive(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	if pvcReclaim.Status.Archive.Phase == v1alpha1.ArchiveUploading {
+		status := pvcReclaim.Status.Archive.DeepCopy()
+		var job batchv1.Job
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: status.JobName}, &job)
+		switch {
+		case errors.IsNotFound(err):
+			status.Message = fmt.Sprintf("upload Job %s was deleted", status.JobName)
+		case err != nil:
+			return ctrl.Result{}, err
+		case jobConditionTrue(&job, batchv1.JobFailed), jobConditionTrue(&job, batchv1.JobComplete):
+			message, err := r.archiveTerminationMessage(ctx, &job)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if jobConditionTrue(&job, batchv1.JobComplete) {
+				status.SHA256 = message
+				status.Message = fmt.Sprintf("Archived to %s/%s", status.Bucket, status.ObjectKey)
+			} else {
+				status.Message = fmt.Sprintf("upload Job %s failed: %s", status.JobName, message)
+			}
+		default:
+			return ctrl.Result{RequeueAfter: archivePollInterval}, nil
+		}
+
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		status.Phase = v1alpha1.ArchiveReleasing
+		pvcReclaim.Status.Archive = status
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	status := pvcReclaim.Status.Archive
+	if leftover, err := r.deleteObjects(ctx,
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: status.JobName}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: status.JobName}},
+	); err != nil || leftover {
+		return ctrl.Result{RequeueAfter: archivePollInterval}, err
+	}
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.ClaimRef = reservedClaimRef(pvcReclaim)
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	now := metav1.Now()
+	pvcReclaim.Status.Archive.Phase = v1alpha1.ArchiveFailed
+	if pvcReclaim.Status.Archive.SHA256 != "" {
+		pvcReclaim.Status.Archive.Phase = v1alpha1.ArchiveCompleted
+	}
+	pvcReclaim.Status.Archive.CompletionTime = &now
+	// a purge held for the archive goes ahead on the next reconcile
+	return ctrl.Result{Requeue: pvcReclaim.Spec.Purge}, r.client.Status().Patch(ctx, pvcReclaim, statusPatch)
+}
+
+// archiveBeforePurge holds the purge of the PV until its contents are archived
+func (r *PVCReclaimController) archiveBeforePurge(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	if pvcReclaim.Status.Archive == nil {
+		return r.startArchive(ctx, pvcReclaim, pv)
+	}
+	reason := fmt.Sprintf("Purge of PV %s is held as it could not be archived, remove archive to purge it anyway: %s", pv.Name, pvcReclaim.Status.Archive.Message)
+	if pvcReclaim.Status.Reason == reason {
+		return ctrl.Result{}, nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Reason = reason
+	return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// keepArchive keeps the PVCReclaim after its PV was purged so the archive can
+// be restored. The recorded PV spec is dropped as its backing volume is gone.
+func (r *PVCReclaimController) keepArchive(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) error {
+	log.FromContext(ctx).Info("PV is purged, keeping PVCReclaim for its archive", "pv", pvcReclaim.Spec.PersistentVolumeRef.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.Purge = false
+	pvcReclaim.Spec.PersistentVolumeSpec = nil
+	if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+		return err
+	}
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.RecoverStatus = v1alpha1.NotRecovered
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// restoreFromArchive restores a PVCReclaim whose PV is gone by provisioning a
+// new PVC with the recorded claim spec and extracting the archive into it. The
+// PVC is labeled as transient until the archive is extracted, the PVCReclaim
+// is deleted once it is. pvc is nil when no PVC with the name of the
+// PVCReclaim exists.
+func (r *PVCReclaimController) restoreFromArchive(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pvc *corev1.PersistentVolumeClaim) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	status := pvcReclaim.Status.Archive
+	pvName := pvcReclaim.Spec.PersistentVolumeRef.Name
+
+	if status.RestoreJobName != "" {
+		return r.waitForUnarchive(ctx, pvcReclaim, pvc)
+	}
+
+	if !pvcReclaim.Spec.Restore {
+		return ctrl.Result{}, r.markPersistentVolumeMissing(ctx, pvcReclaim, fmt.Sprintf("PV %s was deleted, set restore to provision a new PVC from archive %s/%s", pvName, status.Bucket, status.ObjectKey))
+	}
+
+	var blockers []string
+	if pvc != nil {
+		blockers = append(blockers, fmt.Sprintf("PVC %s/%s already exists, it cannot be restored from archive %s/%s", pvc.Namespace, pvc.Name, status.Bucket, status.ObjectKey))
+	}
+	var secret corev1.Secret
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: status.CredentialsSecretName}, &secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		blockers = append(blockers, fmt.Sprintf("credentials Secret %s/%s does not exist", pvcReclaim.Namespace, status.CredentialsSecretName))
+	}
+	restored := restoredPersistentVolumeClaim(pvcReclaim, r.sanitizer)
+	restored.Spec.VolumeName = ""
+	if restored.Labels == nil {
+		restored.Labels = map[string]string{}
+	}
+	restored.Labels[archiveLabel] = pvcReclaim.Name
+
+	if pvcReclaim.Spec.DryRun {
+		manifest, err := renderManifest(restored, "PersistentVolumeClaim")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.recordRestorePlan(ctx, pvcReclaim, &v1alpha1.RestorePlan{
+			Ready:                 len(blockers) == 0,
+			Blockers:              blockers,
+			PersistentVolumeClaim: manifest,
+		})
+	}
+
+	if len(blockers) > 0 {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = strings.Join(blockers, "; ")
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	job := r.archiveJob(pvcReclaim, pvcReclaim.Name+"-unarchive", status, unarchiveScript, restored, false)
+	if err := controllerutil.SetControllerReference(pvcReclaim, job, r.client.Scheme()); err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("Restoring PVC from archive", "pv", pvName, "bucket", status.Bucket, "objectKey", status.ObjectKey, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := r.client.Create(ctx, restored); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	meta.RemoveStatusCondition(&pvcReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing)
+	pvcReclaim.Status.Archive.RestoreJobName = job.Name
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
+	pvcReclaim.Status.Reason = ""
+	pvcReclaim.Status.Message = fmt.Sprintf("Extracting archive %s/%s into PVC %s/%s", status.Bucket, status.ObjectKey, restored.Namespace, restored.Name)
+	if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.Restore = false
+	if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: archivePollInterval}, nil
+}
+
+// waitForUnarchive follows the Job extracting the archive. Once it completes
+// the restored PVC is handed to the PVCController and the PVCReclaim deleted.
+// If it fails the partially restored PVC is deleted so the restore can be retried.
+func (r *PVCReclaimController) waitForUnarchive(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pvc *corev1.PersistentVolumeClaim) (ctrl.Result, error) {
+	status := pvcReclaim.Status.Archive
+	var job batchv1.Job
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: status.RestoreJobName}, &job)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	if err == nil && jobConditionTrue(&job, batchv1.JobComplete) && pvc != nil {
+		patch := client.MergeFrom(pvc.DeepCopy())
+		delete(pvc.Labels, archiveLabel)
+		if err := r.client.Patch(ctx, pvc, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoverySuccess
+		pvcReclaim.Status.Reason = ""
+		pvcReclaim.Status.Message = fmt.Sprintf("Successfully restored PVC %s/%s from archive %s/%s", pvc.Namespace, pvc.Name, status.Bucket, status.ObjectKey)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.FromContext(ctx).Info("Deleting PVCReclaim after successfully restoring PVC from archive", "objectKey", status.ObjectKey, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		return ctrl.Result{}, r.deletePVCReclaim(ctx, pvcReclaim)
+	}
+
+	// the failure is recorded before the Job is deleted, which would otherwise become the reason
+	if pvcReclaim.Status.RecoverStatus != v1alpha1.RecoveryFailed {
+		var reason string
+		switch {
+		case errors.IsNotFound(err):
+			reason = fmt.Sprintf("restore Job %s was deleted", status.RestoreJobName)
+		case pvc == nil:
+			reason = fmt.Sprintf("restored PVC %s/%s was deleted", pvcReclaim.Namespace, pvcReclaim.Name)
+		case jobConditionTrue(&job, batchv1.JobFailed):
+			message, err := r.archiveTerminationMessage(ctx, &job)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			reason = fmt.Sprintf("restore Job %s failed: %s", status.RestoreJobName, message)
+		default:
+			return ctrl.Result{RequeueAfter: archivePollInterval}, nil
+		}
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.Archive.Message = reason
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("Restore from archive failed: %s", reason)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	objects := []client.Object{&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: status.RestoreJobName}}}
+	if pvc != nil && pvc.Labels[archiveLabel] == pvcReclaim.Name {
+		objects = append(objects, pvc)
+	}
+	if leftover, err := r.deleteObjects(ctx, objects...); err != nil || leftover {
+		return ctrl.Result{RequeueAfter: archivePollInterval}, err
+	}
+	// the partially restored PVC is gone, the restore can be retried
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Archive.RestoreJobName = ""
+	return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
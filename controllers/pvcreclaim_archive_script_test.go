@@ -0,0 +1,164 @@
+//go:build archive
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The tests in this file run the scripts of the archive Jobs against an
+// in-memory S3 server and need rclone, tar and sha256sum on the PATH:
+//
+//	go test -tags archive ./controllers/ -run ArchiveScript
+
+// archiveShell returns a shell supporting pipefail like the busybox sh of the archive image, which dash does not
+func archiveShell(t *testing.T) []string {
+	if _, err := exec.LookPath("rclone"); err != nil {
+		t.Skip("rclone is not installed")
+	}
+	if path, err := exec.LookPath("busybox"); err == nil {
+		return []string{path, "sh", "-c"}
+	}
+	if path, err := exec.LookPath("bash"); err == nil {
+		return []string{path, "-c"}
+	}
+	t.Skip("neither busybox nor bash is installed")
+	return nil
+}
+
+// newArchiveServer returns an S3 server with the bucket of the archive fixtures
+func newArchiveServer(t *testing.T) (*httptest.Server, gofakes3.Backend) {
+	backend := s3mem.New()
+	assert.NoError(t, backend.CreateBucket("pvc-archive"))
+	server := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(server.Close)
+	return server, backend
+}
+
+// runArchiveScript runs the script of the Job with /data pointing at the directory and returns its termination message
+func runArchiveScript(t *testing.T, shell []string, endpoint, script, dataDir, sha string) (string, error) {
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Archive = &v1alpha1.Archive{
+		Endpoint:              endpoint,
+		Bucket:                "pvc-archive",
+		Prefix:                "cluster-a/",
+		CredentialsSecretName: "archive-credentials",
+	}
+	status := &v1alpha1.ArchiveStatus{
+		Endpoint:              endpoint,
+		Bucket:                reclaim.Spec.Archive.Bucket,
+		ObjectKey:             archiveObjectKey(reclaim, pv.Name),
+		SHA256:                sha,
+		CredentialsSecretName: reclaim.Spec.Archive.CredentialsSecretName,
+	}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-reclaim-archive", Namespace: "default"}}
+	container := NewPVCReclaimController(nil).archiveJob(reclaim, "test-reclaim-archive", status, script, pvc, true).Spec.Template.Spec.Containers[0]
+
+	tmpDir := t.TempDir()
+	terminationLog := filepath.Join(tmpDir, "termination-log")
+	rcloneConfig := filepath.Join(tmpDir, "rclone.conf")
+	assert.NoError(t, os.WriteFile(rcloneConfig, nil, 0o600))
+	paths := strings.NewReplacer("/data", dataDir, "/tmp/", tmpDir+"/", "/dev/termination-log", terminationLog)
+
+	credentials := map[string]string{"AWS_ACCESS_KEY_ID": "access-key", "AWS_SECRET_ACCESS_KEY": "secret-key"}
+	env := []string{"PATH=" + os.Getenv("PATH"), "HOME=" + tmpDir, "RCLONE_CONFIG=" + rcloneConfig}
+	for _, envVar := range container.Env {
+		value := envVar.Value
+		if envVar.ValueFrom != nil {
+			value = credentials[envVar.ValueFrom.SecretKeyRef.Key]
+		}
+		env = append(env, envVar.Name+"="+value)
+	}
+
+	cmd := exec.Command(shell[0], append(shell[1:], paths.Replace(container.Command[2]))...)
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Logf("script output:\n%s", output)
+	}
+	message, _ := os.ReadFile(terminationLog)
+	return strings.TrimSpace(string(message)), err
+}
+
+// newArchiveData returns a directory with a file and a nested file to archive
+func newArchiveData(t *testing.T) string {
+	dataDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dataDir, "file.txt"), []byte("contents"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dataDir, "nested"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dataDir, "nested", "file.bin"), []byte{0, 1, 2, 3}, 0o644))
+	return dataDir
+}
+
+func TestArchiveScript_UploadAndRestore(t *testing.T) {
+	shell := archiveShell(t)
+	server, backend := newArchiveServer(t)
+
+	sum, err := runArchiveScript(t, shell, server.URL, archiveScript, newArchiveData(t), "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	object, err := backend.GetObject("pvc-archive", "cluster-a/default/test-reclaim/test-pv.tar.gz", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer object.Contents.Close()
+	contents, err := io.ReadAll(object.Contents)
+	assert.NoError(t, err)
+	digest := sha256.Sum256(contents)
+	assert.Equal(t, hex.EncodeToString(digest[:]), sum)
+
+	restoreDir := t.TempDir()
+	message, err := runArchiveScript(t, shell, server.URL, unarchiveScript, restoreDir, sum)
+	assert.NoError(t, err)
+	assert.Empty(t, message)
+
+	file, err := os.ReadFile(filepath.Join(restoreDir, "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "contents", string(file))
+	nested, err := os.ReadFile(filepath.Join(restoreDir, "nested", "file.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2, 3}, nested)
+}
+
+func TestArchiveScript_RestoreChecksumMismatch(t *testing.T) {
+	shell := archiveShell(t)
+	server, _ := newArchiveServer(t)
+
+	sum, err := runArchiveScript(t, shell, server.URL, archiveScript, newArchiveData(t), "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	restoreDir := t.TempDir()
+	message, err := runArchiveScript(t, shell, server.URL, unarchiveScript, restoreDir, testArchiveSHA256)
+	assert.Error(t, err)
+	assert.Equal(t, "checksum "+sum+" of the archive does not match "+testArchiveSHA256, message)
+
+	// the checksum is only known once the archive is read in full, so it has already been extracted
+	_, err = os.Stat(filepath.Join(restoreDir, "file.txt"))
+	assert.NoError(t, err)
+}
+
+func TestArchiveScript_MissingObject(t *testing.T) {
+	shell := archiveShell(t)
+	server, _ := newArchiveServer(t)
+
+	_, err := runArchiveScript(t, shell, server.URL, unarchiveScript, t.TempDir(), testArchiveSHA256)
+	assert.Error(t, err)
+}
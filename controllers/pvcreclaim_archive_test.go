@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testArchiveSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+// finishArchiveJob completes or fails the Job with a pod terminated with the message
+func finishArchiveJob(t *testing.T, c client.Client, name string, conditionType batchv1.JobConditionType, message string) {
+	ctx := context.Background()
+	var job batchv1.Job
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &job))
+	job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+	assert.NoError(t, c.Status().Update(ctx, &job))
+	assert.NoError(t, c.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-abcde", Namespace: "default", Labels: map[string]string{"job-name": name}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "archive",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message + "\n", FinishedAt: metav1.NewTime(time.Now())}},
+			}},
+		},
+	}))
+}
+
+func TestPVCReclaimController_Reconcile_ArchiveBeforePurge(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Archive = &v1alpha1.Archive{
+		Endpoint:              "http://minio.minio.svc:9000",
+		Bucket:                "pvc-archive",
+		Prefix:                "cluster-a/",
+		CredentialsSecretName: "archive-credentials",
+	}
+	reclaim.Spec.Purge = true
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv, &batchv1.Job{}).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	// the purge is held while the PV is archived
+	var job batchv1.Job
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-reclaim-archive", Namespace: "default"}, &job))
+	env := map[string]corev1.EnvVar{}
+	for _, e := range job.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e
+	}
+	assert.Equal(t, "cluster-a/default/test-reclaim/test-pv.tar.gz", env["OBJECT_KEY"].Value)
+	assert.Equal(t, "http://minio.minio.svc:9000", env["RCLONE_CONFIG_ARCHIVE_ENDPOINT"].Value)
+	assert.Equal(t, "archive-credentials", env["RCLONE_CONFIG_ARCHIVE_ACCESS_KEY_ID"].ValueFrom.SecretKeyRef.Name)
+	assert.True(t, job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "test-reclaim-archive", updatedPV.Spec.ClaimRef.Name)
+	assert.Equal(t, corev1.PersistentVolumeReclaimRetain, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+
+	finishArchiveJob(t, fakeClient, "test-reclaim-archive", batchv1.JobComplete, testArchiveSHA256)
+	for i := 0; i < 2; i++ {
+		_, err = controller.Reconcile(ctx, req)
+		assert.NoError(t, err)
+	}
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.ArchiveCompleted, updatedReclaim.Status.Archive.Phase)
+	assert.Equal(t, testArchiveSHA256, updatedReclaim.Status.Archive.SHA256)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+
+	// the purge goes ahead once the PV is archived
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+
+	// the PVCReclaim outlives the purged PV for its archive
+	assert.NoError(t, fakeClient.Delete(ctx, &updatedPV))
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Purge)
+	assert.Nil(t, updatedReclaim.Spec.PersistentVolumeSpec)
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing))
+}
+
+func TestPVCReclaimController_Reconcile_ArchiveFailedHoldsPurge(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Archive = &v1alpha1.Archive{
+		Endpoint:              "http://minio.minio.svc:9000",
+		Bucket:                "pvc-archive",
+		Prefix:                "cluster-a/",
+		CredentialsSecretName: "archive-credentials",
+	}
+	reclaim.Spec.Purge = true
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv, &batchv1.Job{}).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	finishArchiveJob(t, fakeClient, "test-reclaim-archive", batchv1.JobFailed, "Failed to rcat: AccessDenied")
+	for i := 0; i < 3; i++ {
+		_, err = controller.Reconcile(ctx, req)
+		assert.NoError(t, err)
+	}
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.ArchiveFailed, updatedReclaim.Status.Archive.Phase)
+	assert.Contains(t, updatedReclaim.Status.Reason, "AccessDenied")
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, corev1.PersistentVolumeReclaimRetain, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+
+	// removing the archive forgets the failure and lets the purge go ahead
+	updatedReclaim.Spec.Archive = nil
+	assert.NoError(t, fakeClient.Update(ctx, &updatedReclaim))
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Nil(t, updatedReclaim.Status.Archive)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+}
+
+func TestPVCReclaimController_Reconcile_RestoreFromArchive(t *testing.T) {
+	ctx := context.Background()
+	reclaim, _ := newReleasedFixtures()
+	reclaim.Spec.Archive = &v1alpha1.Archive{
+		Endpoint:              "http://minio.minio.svc:9000",
+		Bucket:                "pvc-archive",
+		Prefix:                "cluster-a/",
+		CredentialsSecretName: "archive-credentials",
+	}
+	reclaim.Spec.Restore = true
+	reclaim.Spec.PersistentVolumeSpec = nil
+	reclaim.Status.Archive = &v1alpha1.ArchiveStatus{
+		Phase:                 v1alpha1.ArchiveCompleted,
+		JobName:               "test-reclaim-archive",
+		Endpoint:              "http://minio.minio.svc:9000",
+		Bucket:                "pvc-archive",
+		ObjectKey:             "cluster-a/default/test-reclaim/test-pv.tar.gz",
+		CredentialsSecretName: "archive-credentials",
+		SHA256:                testArchiveSHA256,
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "archive-credentials", Namespace: "default"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, &batchv1.Job{}).WithObjects(reclaim, secret).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	var restored corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &restored))
+	assert.Empty(t, restored.Spec.VolumeName)
+	assert.True(t, transientClaim(&restored))
+	var job batchv1.Job
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-reclaim-unarchive", Namespace: "default"}, &job))
+	assert.False(t, job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryInProgress, updatedReclaim.Status.RecoverStatus)
+
+	finishArchiveJob(t, fakeClient, "test-reclaim-unarchive", batchv1.JobComplete, "")
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &restored))
+	assert.False(t, transientClaim(&restored))
+	err = fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_RestoreFromArchiveFailed(t *testing.T) {
+	ctx := context.Background()
+	reclaim, _ := newReleasedFixtures()
+	reclaim.Spec.Archive = &v1alpha1.Archive{
+		Endpoint:              "http://minio.minio.svc:9000",
+		Bucket:                "pvc-archive",
+		Prefix:                "cluster-a/",
+		CredentialsSecretName: "archive-credentials",
+	}
+	reclaim.Spec.PersistentVolumeSpec = nil
+	reclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
+	reclaim.Status.Archive = &v1alpha1.ArchiveStatus{
+		Phase:                 v1alpha1.ArchiveCompleted,
+		ObjectKey:             "cluster-a/default/test-reclaim/test-pv.tar.gz",
+		CredentialsSecretName: "archive-credentials",
+		SHA256:                testArchiveSHA256,
+		RestoreJobName:        "test-reclaim-unarchive",
+	}
+	restored := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-reclaim", Namespace: "default", Labels: map[string]string{archiveLabel: "test-reclaim"}}}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "test-reclaim-unarchive", Namespace: "default"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, job).WithObjects(reclaim, restored, job).Build()
+	controller := NewPVCReclaimController(fakeClient)
+	finishArchiveJob(t, fakeClient, "test-reclaim-unarchive", batchv1.JobFailed, "checksum 0000 of the archive does not match "+testArchiveSHA256)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	for i := 0; i < 2; i++ {
+		_, err := controller.Reconcile(ctx, req)
+		assert.NoError(t, err)
+	}
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Contains(t, updatedReclaim.Status.Reason, "does not match")
+	assert.Empty(t, updatedReclaim.Status.Archive.RestoreJobName)
+	err := fakeClient.Get(ctx, req.NamespacedName, restored)
+	assert.True(t, errors.IsNotFound(err))
+}
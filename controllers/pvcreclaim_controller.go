@@ -20,40 +20,162 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
 )
 
 const (
-	reclaimPVLabel     = "pvc-reclaim.yibozhuang.me/pv-name"
-	pvAnnotationPrefix = "pv.kubernetes.io"
+	reclaimPVLabel = "pvc-reclaim.yibozhuang.me/pv-name"
 )
 
 // PVCReclaimController reconciles a PVCReclaim object
 type PVCReclaimController struct {
-	client client.Client
+	client                client.Client
+	csiControllerEndpoint string
+	resync                <-chan event.GenericEvent
+	preflightChecks       []PreflightCheck
+	sanitizer             *Sanitizer
+	pvAnnotationPolicy    PersistentVolumeAnnotationPolicy
+	migrationImage        string
+	// migrationStagingNamespace is where migrations copy data unless the PVCReclaim names a namespace
+	migrationStagingNamespace string
+	previewImage              string
+	previewTimeout            time.Duration
+	// podLogs reads the output of content manifest Jobs, the manifest is disabled without it
+	podLogs                  PodLogReader
+	contentManifestImage     string
+	contentManifestChecksums bool
+	archiveImage             string
 }
 
 var _ reconcile.Reconciler = &PVCReclaimController{}
 
-func NewPVCReclaimController(client client.Client) *PVCReclaimController {
-	return &PVCReclaimController{
-		client: client,
+// PVCReclaimControllerOption configures optional behavior of the PVCReclaimController
+type PVCReclaimControllerOption func(*PVCReclaimController)
+
+// WithCSIControllerEndpoint sets the CSI controller socket used to delete backing volumes on purge
+func WithCSIControllerEndpoint(endpoint string) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.csiControllerEndpoint = endpoint
+	}
+}
+
+// WithResyncEvents queues PVCReclaims sent on the channel for reconcile, e.g. by the DriftSweeper
+func WithResyncEvents(resync <-chan event.GenericEvent) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.resync = resync
+	}
+}
+
+// WithPreflightChecks replaces the checks run before a restore, DefaultPreflightChecks by default
+func WithPreflightChecks(checks ...PreflightCheck) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.preflightChecks = checks
+	}
+}
+
+// WithSanitizer sets the rules stripping PVC metadata and spec fields on capture and restore, DefaultSanitizer by default
+func WithSanitizer(sanitizer *Sanitizer) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.sanitizer = sanitizer
+	}
+}
+
+// WithPersistentVolumeAnnotationPolicy sets which PV annotations a restore removes, DefaultPersistentVolumeAnnotationPolicy by default
+func WithPersistentVolumeAnnotationPolicy(policy PersistentVolumeAnnotationPolicy) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.pvAnnotationPolicy = policy
+	}
+}
+
+// WithMigrationImage sets the image with rsync copying data into another StorageClass, DefaultMigrationImage by default
+func WithMigrationImage(image string) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.migrationImage = image
+	}
+}
+
+// WithMigrationStagingNamespace sets where migrations copy data, DefaultMigrationStagingNamespace by default
+func WithMigrationStagingNamespace(namespace string) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.migrationStagingNamespace = namespace
+	}
+}
+
+// WithPreviewImage sets the image of the inspection pod mounting a previewed PV, DefaultPreviewImage by default
+func WithPreviewImage(image string) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.previewImage = image
+	}
+}
+
+// WithPreviewTimeout sets how long a preview runs before it is torn down, DefaultPreviewTimeout by default
+func WithPreviewTimeout(timeout time.Duration) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.previewTimeout = timeout
+	}
+}
+
+// WithContentManifest scans the content of PVs once they are Released, reading the output of the scan with the PodLogReader
+func WithContentManifest(podLogs PodLogReader) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.podLogs = podLogs
+	}
+}
+
+// WithContentManifestImage sets the image of the Job scanning a released PV, DefaultContentManifestImage by default
+func WithContentManifestImage(image string) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.contentManifestImage = image
+	}
+}
+
+// WithContentManifestChecksums adds a sha256 checksum of every file to the content manifest
+func WithContentManifestChecksums(checksums bool) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.contentManifestChecksums = checksums
+	}
+}
+
+// WithArchiveImage sets the image uploading and extracting archives of PVs, DefaultArchiveImage by default
+func WithArchiveImage(image string) PVCReclaimControllerOption {
+	return func(r *PVCReclaimController) {
+		r.archiveImage = image
+	}
+}
+
+func NewPVCReclaimController(client client.Client, opts ...PVCReclaimControllerOption) *PVCReclaimController {
+	r := &PVCReclaimController{
+		client:                    client,
+		preflightChecks:           DefaultPreflightChecks(),
+		sanitizer:                 DefaultSanitizer(),
+		pvAnnotationPolicy:        DefaultPersistentVolumeAnnotationPolicy(),
+		migrationImage:            DefaultMigrationImage,
+		migrationStagingNamespace: DefaultMigrationStagingNamespace,
+		previewImage:              DefaultPreviewImage,
+		previewTimeout:            DefaultPreviewTimeout,
+		contentManifestImage:      DefaultContentManifestImage,
+		archiveImage:              DefaultArchiveImage,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
 //+kubebuilder:rbac:groups=yibozhuang.me,resources=pvcreclaims,verbs=get;list;watch;create;update;patch;delete
@@ -83,6 +205,9 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 	if errors.IsNotFound(err) && pvcFetchErr == nil {
+		if transientClaim(&pvc) {
+			return ctrl.Result{}, nil
+		}
 		if pvc.Spec.VolumeName == "" || pvc.Status.Phase != corev1.ClaimBound {
 			logger.Info("PVC is not Bound, nothing to be done", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
 			return ctrl.Result{}, nil
@@ -102,18 +227,17 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 					Kind:       pv.Kind,
 					APIVersion: pv.APIVersion,
 					Name:       pv.Name,
+					UID:        pv.UID,
 				},
-				PersistentVolumeClaimSpec: pvc.Spec,
+				PersistentVolumeClaimSpec: r.sanitizer.ClaimSpec(&pvc.Spec),
+				PersistentVolumeSpec:      sanitizedPersistentVolumeSpec(&pv),
 				Restore:                   false,
 			},
 		}
-		if pvc.Annotations != nil {
-			pvcReclaim.Annotations = pvc.Annotations
-		}
-		if pvc.Labels == nil {
+		pvcReclaim.Annotations = r.sanitizer.Annotations(pvc.Annotations)
+		pvcReclaim.Labels = r.sanitizer.Labels(pvc.Labels)
+		if pvcReclaim.Labels == nil {
 			pvcReclaim.Labels = make(map[string]string)
-		} else {
-			pvcReclaim.Labels = pvc.Labels
 		}
 		pvcReclaim.Labels[reclaimPVLabel] = pv.Name
 		if err := r.client.Create(ctx, &pvcReclaim); err != nil && !errors.IsAlreadyExists(err) {
@@ -124,6 +248,7 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 		patch := client.MergeFrom(pvcReclaim.DeepCopy())
 		pvcReclaim.Status.Reason = ""
 		pvcReclaim.Status.RecoverStatus = v1alpha1.NotRecovered
+		pvcReclaim.Status.ClaimUID = pvc.UID
 		pvcReclaim.Status.Message = fmt.Sprintf("PVC %s Bound, PVCReclaim %s created for recovery", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
 		if err := r.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
 			return ctrl.Result{}, err
@@ -131,28 +256,124 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
-	deletePolicy := metav1.DeletePropagationForeground
 	err = r.client.Get(ctx, types.NamespacedName{Name: pvcReclaim.Spec.PersistentVolumeRef.Name}, &pv)
 	if err != nil && !errors.IsNotFound(err) {
 		return ctrl.Result{}, err
 	}
+
+	pvRef := &pv
 	if errors.IsNotFound(err) {
-		logger.Info("PV is not found, deleting PVCReclaim", "pv", pvcReclaim.Spec.PersistentVolumeRef.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
-		if innerErr := r.client.Delete(ctx, &pvcReclaim, &client.DeleteOptions{
-			GracePeriodSeconds: &[]int64{0}[0],
-			PropagationPolicy:  &deletePolicy,
-		}); innerErr != nil {
-			return ctrl.Result{}, innerErr
+		pvRef = nil
+	}
+	stale := false
+	if pvRef != nil {
+		trusted, verifyErr := r.verifyPersistentVolume(ctx, &pvcReclaim, pvRef)
+		if verifyErr != nil {
+			return ctrl.Result{}, verifyErr
 		}
+		if stale = !trusted; stale {
+			// the original PV is gone, the PV now under its name must be left alone
+			pvRef = nil
+		}
+	}
+	if syncErr := r.syncPersistentVolumeStatus(ctx, &pvcReclaim, pvRef); syncErr != nil {
+		return ctrl.Result{}, syncErr
+	}
+	if syncErr := r.syncLegalHold(ctx, &pvcReclaim, pvRef); syncErr != nil {
+		return ctrl.Result{}, syncErr
+	}
+	if syncErr := r.syncSnapshotStatus(ctx, &pvcReclaim); syncErr != nil {
+		return ctrl.Result{}, syncErr
+	}
+	if syncErr := r.syncArchiveStatus(ctx, &pvcReclaim); syncErr != nil {
+		return ctrl.Result{}, syncErr
+	}
+	if pvcReclaim.DeletionTimestamp != nil {
+		return r.handleDeletion(ctx, &pvcReclaim, pvRef)
+	}
+	if err := r.ensureProtectionFinalizer(ctx, &pvcReclaim); err != nil {
+		return ctrl.Result{}, err
+	}
+	if stale {
 		return ctrl.Result{}, nil
 	}
+	if pvcReclaim.Status.ConsumedBy != nil {
+		return r.handleConsumed(ctx, &pvcReclaim, pvRef)
+	}
+
+	pvcRef := &pvc
+	if pvcFetchErr != nil {
+		pvcRef = nil
+	}
+	if errors.IsNotFound(err) {
+		return r.handleMissingPersistentVolume(ctx, &pvcReclaim, pvcRef)
+	}
+
+	if err := r.recordPersistentVolumeSpec(ctx, &pvcReclaim, &pv); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the PV is bound to a scratch PVC while its content is scanned, archived or previewed
+	if contentScanActive(&pvcReclaim) {
+		return r.scanContent(ctx, &pvcReclaim, &pv)
+	}
+	if archiveActive(&pvcReclaim) {
+		return r.archive(ctx, &pvcReclaim, &pv)
+	}
+	if pvcReclaim.Spec.Preview || pvcReclaim.Status.Preview != nil {
+		return r.preview(ctx, &pvcReclaim, &pv)
+	}
+
+	// the PV is bound to a staging PVC while its data is copied into another StorageClass
+	if migrationActive(&pvcReclaim) || (pvcReclaim.Spec.Restore && pvcReclaim.Spec.MigrateTo != nil) {
+		return r.migrate(ctx, &pvcReclaim, &pv)
+	}
+
+	if pvcReclaim.Spec.ReleaseToPool != nil {
+		return r.releaseToPool(ctx, &pvcReclaim, &pv)
+	}
+
+	if pvcReclaim.Spec.Purge {
+		return r.purge(ctx, &pvcReclaim, &pv)
+	}
+
+	superseded, err := r.syncSuperseded(ctx, &pvcReclaim, &pv, pvcRef)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !superseded {
+		if err := r.reserveClaimRef(ctx, &pvcReclaim, &pv); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.mirrorClaimRecord(ctx, &pvcReclaim, &pv); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	if !pvcReclaim.Spec.Restore {
+		if r.podLogs != nil && pvcReclaim.Status.ContentManifest == nil && !superseded && pv.Status.Phase == corev1.VolumeReleased {
+			return r.startContentScan(ctx, &pvcReclaim, &pv)
+		}
+		if pvcReclaim.Spec.Archive != nil && pvcReclaim.Status.Archive == nil && !superseded && pv.Status.Phase == corev1.VolumeReleased {
+			return r.startArchive(ctx, &pvcReclaim, &pv)
+		}
 		return ctrl.Result{}, nil
 	}
 
-	// Check to ensure PV is in Released phase
-	if pv.Status.Phase != corev1.VolumeReleased {
+	if pvcReclaim.Spec.DryRun {
+		var blockers []string
+		if superseded && !pvcReclaim.Spec.Force {
+			blockers = append(blockers, fmt.Sprintf("PV %s is bound to another claim, set force to take it back", pv.Name))
+		}
+		if !superseded && !restorable(&pvcReclaim, &pv) {
+			blockers = append(blockers, fmt.Sprintf("PV %s is not in Released phase", pv.Name))
+		}
+		return ctrl.Result{}, r.planRestore(ctx, &pvcReclaim, &pv, nil, false, blockers)
+	}
+
+	// Check to ensure PV is in Released phase, unless a forced restore takes it back from another claim
+	if !superseded && !restorable(&pvcReclaim, &pv) {
 		logger.Info("PV is not in Released phase", "pv", pvcReclaim.Spec.PersistentVolumeRef.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
 		patch := client.MergeFrom(pvcReclaim.DeepCopy())
 		pvcReclaim.Spec.Restore = false
@@ -168,6 +389,25 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
+	failures, err := r.runPreflightChecks(ctx, &pvcReclaim, &pv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(failures) > 0 {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, &pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("Preflight checks failed: %s", strings.Join(failures, "; "))
+		if err := r.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	patch := client.MergeFrom(pvcReclaim.DeepCopy())
 	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
 	pvcReclaim.Status.Message = fmt.Sprintf("Recovering PVC %s and having it bound to PV %s", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), pv.Name)
@@ -177,16 +417,7 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	// recreate the PVC
-	pvc = corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        pvcReclaim.Name,
-			Namespace:   pvcReclaim.Namespace,
-			Labels:      pvcReclaim.Labels,
-			Annotations: pvcReclaim.Annotations,
-		},
-		Spec: pvcReclaim.Spec.PersistentVolumeClaimSpec,
-	}
-	delete(pvc.Labels, reclaimPVLabel)
+	pvc = *restoredPersistentVolumeClaim(&pvcReclaim, r.sanitizer)
 	if err := r.client.Create(ctx, &pvc); err != nil && !errors.IsAlreadyExists(err) {
 		patch := client.MergeFrom(pvcReclaim.DeepCopy())
 		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
@@ -210,12 +441,16 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	patch = client.MergeFrom(pv.DeepCopy())
-	for annKey := range pv.Annotations {
-		if !strings.Contains(annKey, pvAnnotationPrefix) {
-			delete(pv.Annotations, annKey)
+	removed := bindPersistentVolume(&pv, &pvc, r.pvAnnotationPolicy)
+	if len(removed) > 0 {
+		// keep the removed annotations so they can be reinstated
+		logger.Info("Removing PV annotations on restore", "pv", pv.Name, "annotations", removed, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RemovedPersistentVolumeAnnotations = removed
+		if err := r.client.Status().Patch(ctx, &pvcReclaim, statusPatch); err != nil {
+			return ctrl.Result{}, err
 		}
 	}
-	pv.Spec.ClaimRef.UID = pvc.UID
 	if err := r.client.Patch(ctx, &pv, patch); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -229,30 +464,90 @@ func (r *PVCReclaimController) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	logger.Info("Deleting PVCReclaim after successfully recovering PVC", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
-	if err := r.client.Delete(ctx, &pvcReclaim, &client.DeleteOptions{
-		GracePeriodSeconds: &[]int64{0}[0],
-		PropagationPolicy:  &deletePolicy,
-	}); err != nil {
+	if err := r.deletePVCReclaim(ctx, &pvcReclaim); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *PVCReclaimController) SetupWithManager(mgr ctrl.Manager) error {
-	pvPredicate := predicate.Funcs{
-		CreateFunc: func(e event.CreateEvent) bool {
-			return false
-		},
-		UpdateFunc: func(e event.UpdateEvent) bool {
-			return true
-		},
-		DeleteFunc: func(e event.DeleteEvent) bool {
-			return true
-		},
+// deletePVCReclaim deletes the PVCReclaim once it is no longer needed for recovery,
+// unless it is kept around by a legal hold
+func (r *PVCReclaimController) deletePVCReclaim(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) error {
+	if legalHoldActive(pvcReclaim) {
+		log.FromContext(ctx).Info("PVCReclaim is under legal hold, keeping it", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		return nil
+	}
+	if err := r.removeProtectionFinalizer(ctx, pvcReclaim); err != nil {
+		return err
+	}
+	deletePolicy := metav1.DeletePropagationForeground
+	return r.client.Delete(ctx, pvcReclaim, &client.DeleteOptions{
+		GracePeriodSeconds: &[]int64{0}[0],
+		PropagationPolicy:  &deletePolicy,
+	})
+}
+
+// reservedClaimRef returns the claimRef reserving a PV for the deleted PVC of the PVCReclaim
+func reservedClaimRef(pvcReclaim *v1alpha1.PVCReclaim) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  pvcReclaim.Namespace,
+		Name:       pvcReclaim.Name,
+		UID:        pvcReclaim.Status.ClaimUID,
+	}
+}
+
+// reserveClaimRef keeps the claimRef of a PV that is not Bound pinned to the
+// PVC it was released from, so no other PVC can bind to it outside of a restore.
+func (r *PVCReclaimController) reserveClaimRef(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) error {
+	logger := log.FromContext(ctx)
+
+	if pv.Status.Phase == corev1.VolumeBound || pv.DeletionTimestamp != nil {
+		return nil
+	}
+	if meta.IsStatusConditionTrue(pvcReclaim.Status.Conditions, v1alpha1.ConditionDuplicate) {
+		// another PVCReclaim references the PV, re-asserting would fight over the claimRef
+		return nil
+	}
+
+	claimRef := pv.Spec.ClaimRef
+	if claimRef != nil && claimRef.Namespace == pvcReclaim.Namespace && claimRef.Name == pvcReclaim.Name {
+		if claimRef.UID == "" || pvcReclaim.Status.ClaimUID != "" {
+			return nil
+		}
+		// remember the UID of the released PVC so the reservation can be re-asserted later
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.ClaimUID = claimRef.UID
+		return r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	drifted := "<none>"
+	if claimRef != nil {
+		drifted = fmt.Sprintf("%s/%s", claimRef.Namespace, claimRef.Name)
+	}
+	logger.Info("PV claimRef drifted from the original PVC, re-asserting it", "pv", pv.Name, "claimRef", drifted, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.ClaimRef = reservedClaimRef(pvcReclaim)
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return err
 	}
 
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionClaimRefDrift,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             "ClaimRefReasserted",
+		Message:            fmt.Sprintf("claimRef of PV %s was changed to %s, re-asserted to %s/%s", pv.Name, drifted, pvcReclaim.Namespace, pvcReclaim.Name),
+	})
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PVCReclaimController) SetupWithManager(mgr ctrl.Manager) error {
 	pvEnqueuePVCReclaimReconcileRequestMapFunc := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
 		pv, ok := object.(*corev1.PersistentVolume)
 		if !ok {
@@ -290,8 +585,12 @@ func (r *PVCReclaimController) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.PVCReclaim{}).
-		Watches(&corev1.PersistentVolume{}, pvEnqueuePVCReclaimReconcileRequestMapFunc, builder.WithPredicates(pvPredicate)).
-		Complete(r)
+		// PV creates are watched too, so a PV re-created under the same name is noticed
+		Watches(&corev1.PersistentVolume{}, pvEnqueuePVCReclaimReconcileRequestMapFunc)
+	if r.resync != nil {
+		b = b.WatchesRawSource(source.Channel(r.resync, &handler.EnqueueRequestForObject{}))
+	}
+	return b.Complete(r)
 }
@@ -7,6 +7,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -14,6 +17,62 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// newReleasedFixtures returns a PVCReclaim with the recorded claim of a deleted
+// PVC and its Released PV, whose claimRef still points at that PVC, for
+// tests to set the fields they exercise
+func newReleasedFixtures() (*v1alpha1.PVCReclaim, *corev1.PersistentVolume) {
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+			Labels: map[string]string{
+				reclaimPVLabel: "test-pv",
+			},
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+			PersistentVolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+				VolumeName:  "test-pv",
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("10Gi"),
+					},
+				},
+			},
+		},
+		Status: v1alpha1.PVCReclaimStatus{
+			ClaimUID: types.UID("old-pvc-uid"),
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse("10Gi"),
+			},
+			AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              "standard",
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "default",
+				Name:      "test-reclaim",
+				UID:       types.UID("old-pvc-uid"),
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeReleased,
+		},
+	}
+	return reclaim, pv
+}
+
 func TestPVCReclaimController_Reconcile_ReclaimNotFound(t *testing.T) {
 	s := runtime.NewScheme()
 	_ = v1alpha1.AddToScheme(s)
@@ -118,6 +177,7 @@ func TestPVCReclaimController_Reconcile_PVCReclaimRestore_Success(t *testing.T)
 	s := runtime.NewScheme()
 	_ = v1alpha1.AddToScheme(s)
 	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
 
 	reclaim := &v1alpha1.PVCReclaim{
 		ObjectMeta: metav1.ObjectMeta{
@@ -150,7 +210,7 @@ func TestPVCReclaimController_Reconcile_PVCReclaimRestore_Success(t *testing.T)
 			},
 		},
 	}
-	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(newPreflightNamespace(), reclaim, pv).Build()
 	controller := NewPVCReclaimController(fakeClient)
 
 	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
@@ -216,10 +276,105 @@ func TestPVCReclaimController_Reconcile_ReclaimFound(t *testing.T) {
 		},
 	}
 
-	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(reclaim, pvc, pv).Build()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pvc, pv).WithObjects(reclaim, pvc, pv).Build()
 	controller := NewPVCReclaimController(fakeClient)
 
 	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
 	_, err := controller.Reconcile(context.Background(), req)
 	assert.NoError(t, err)
 }
+
+func TestPVCReclaimController_Reconcile_ClaimRefCleared_Reasserted(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+		},
+		Status: v1alpha1.PVCReclaimStatus{
+			ClaimUID: types.UID("old-pvc-uid"),
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeAvailable,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	if assert.NotNil(t, updatedPV.Spec.ClaimRef) {
+		assert.Equal(t, "default", updatedPV.Spec.ClaimRef.Namespace)
+		assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+		assert.Equal(t, types.UID("old-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+	}
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionClaimRefDrift))
+}
+
+func TestPVCReclaimController_Reconcile_ClaimRefMatches_RecordsClaimUID(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "default",
+				Name:      "test-reclaim",
+				UID:       types.UID("old-pvc-uid"),
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeReleased,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, types.UID("old-pvc-uid"), updatedReclaim.Status.ClaimUID)
+	assert.Nil(t, meta.FindStatusCondition(updatedReclaim.Status.Conditions, v1alpha1.ConditionClaimRefDrift))
+}
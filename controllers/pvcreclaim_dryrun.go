@@ -0,0 +1,146 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// dryRunClaimUID stands in for the UID of the PVC a dry run does not create
+const dryRunClaimUID types.UID = "uid-of-the-restored-pvc"
+
+// restoredPersistentVolumeClaim builds the PVC a restore re-creates from the
+// PVCReclaim, stripped of what the sanitizer rules drop and with the restore
+// overrides applied
+func restoredPersistentVolumeClaim(pvcReclaim *v1alpha1.PVCReclaim, sanitizer *Sanitizer) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pvcReclaim.Name,
+			Namespace:   pvcReclaim.Namespace,
+			Labels:      sanitizer.Labels(pvcReclaim.Labels),
+			Annotations: sanitizer.Annotations(pvcReclaim.Annotations),
+		},
+		Spec: sanitizer.ClaimSpec(&pvcReclaim.Spec.PersistentVolumeClaimSpec),
+	}
+	applyRestoreOverrides(pvc, pvcReclaim.Spec.RestoreOverrides)
+	return pvc
+}
+
+// bindPersistentVolume points the claimRef of the PV at the restored PVC and
+// removes the annotations the policy does not preserve, which it returns
+func bindPersistentVolume(pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim, policy PersistentVolumeAnnotationPolicy) map[string]string {
+	removed := policy.Removed(pv.Annotations)
+	for annKey := range removed {
+		delete(pv.Annotations, annKey)
+	}
+	if pv.Spec.ClaimRef == nil {
+		pv.Spec.ClaimRef = &corev1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		}
+	}
+	pv.Spec.ClaimRef.Namespace = pvc.Namespace
+	pv.Spec.ClaimRef.Name = pvc.Name
+	pv.Spec.ClaimRef.UID = pvc.UID
+	pv.Spec.ClaimRef.ResourceVersion = ""
+	return removed
+}
+
+// renderManifest renders the object as a YAML manifest of the given kind
+func renderManifest(obj client.Object, kind string) (string, error) {
+	obj.GetObjectKind().SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+	manifest, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(manifest), nil
+}
+
+// planRestore renders what a restore of the PVCReclaim would do into
+// status.restorePlan without writing anything else. blockers are the reasons
+// found so far why the restore would be refused, the preflight checks add
+// theirs. pv is the PV the restore binds, which is re-created first when
+// recreate is set, and pvc is a Lost PVC the PV is re-created for, if any,
+// in which case no PVC is created.
+func (r *PVCReclaimController) planRestore(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim, recreate bool, blockers []string) error {
+	failures, err := r.runPreflightChecks(ctx, pvcReclaim, pv)
+	if err != nil {
+		return err
+	}
+	blockers = append(blockers, failures...)
+
+	plan := &v1alpha1.RestorePlan{
+		Ready:    len(blockers) == 0,
+		Blockers: blockers,
+	}
+	restored := pv.DeepCopy()
+	if pvc == nil {
+		pvc = restoredPersistentVolumeClaim(pvcReclaim, r.sanitizer)
+		pvc.UID = dryRunClaimUID
+		if plan.PersistentVolumeClaim, err = renderManifest(pvc.DeepCopy(), "PersistentVolumeClaim"); err != nil {
+			return err
+		}
+		plan.RemovedPersistentVolumeAnnotations = bindPersistentVolume(restored, pvc, r.pvAnnotationPolicy)
+	}
+	if recreate {
+		if plan.PersistentVolume, err = renderManifest(restored, "PersistentVolume"); err != nil {
+			return err
+		}
+	} else {
+		pvPatch, err := client.MergeFrom(pv).Data(restored)
+		if err != nil {
+			return err
+		}
+		plan.PersistentVolumePatch = string(pvPatch)
+	}
+
+	return r.recordRestorePlan(ctx, pvcReclaim, plan)
+}
+
+// recordRestorePlan stores the plan of a dry run in status.restorePlan unless
+// it is unchanged from the recorded one
+func (r *PVCReclaimController) recordRestorePlan(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, plan *v1alpha1.RestorePlan) error {
+	previous := pvcReclaim.Status.RestorePlan
+	if previous != nil {
+		plan.PlannedAt = previous.PlannedAt
+		if equality.Semantic.DeepEqual(previous, plan) {
+			return nil
+		}
+	}
+	plan.PlannedAt = metav1.Now()
+
+	log.FromContext(ctx).Info("Rendered restore plan for dry run", "ready", plan.Ready, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.RestorePlan = plan
+	pvcReclaim.Status.Message = "Dry run rendered the restore plan into status.restorePlan, unset dryRun to restore"
+	if !plan.Ready {
+		pvcReclaim.Status.Message = fmt.Sprintf("Dry run found the restore would be refused: %s", strings.Join(plan.Blockers, "; "))
+	}
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
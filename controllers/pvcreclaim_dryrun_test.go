@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_DryRunRestore(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	reclaim.Spec.DryRun = true
+	reclaim.Labels["app"] = "db"
+	pv.Annotations = map[string]string{
+		"pv.kubernetes.io/provisioned-by":      "test.csi.yibozhuang.me",
+		"pv.kubernetes.io/bound-by-controller": "yes",
+	}
+	storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(newPreflightNamespace(), storageClass, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, updatedReclaim.Spec.Restore)
+	plan := updatedReclaim.Status.RestorePlan
+	if assert.NotNil(t, plan) {
+		assert.True(t, plan.Ready)
+		assert.Empty(t, plan.Blockers)
+		assert.Contains(t, plan.PersistentVolumeClaim, "kind: PersistentVolumeClaim")
+		assert.Contains(t, plan.PersistentVolumeClaim, "app: db")
+		assert.NotContains(t, plan.PersistentVolumeClaim, reclaimPVLabel)
+		assert.Contains(t, plan.PersistentVolumePatch, `"pv.kubernetes.io/bound-by-controller":null`)
+		assert.NotContains(t, plan.PersistentVolumePatch, "provisioned-by")
+		assert.Equal(t, map[string]string{"pv.kubernetes.io/bound-by-controller": "yes"}, plan.RemovedPersistentVolumeAnnotations)
+		assert.Contains(t, plan.PersistentVolumePatch, string(dryRunClaimUID))
+		assert.Empty(t, plan.PersistentVolume)
+	}
+	assert.Len(t, updatedReclaim.Status.PreflightChecks, len(DefaultPreflightChecks()))
+
+	// nothing was written for the restore
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &corev1.PersistentVolumeClaim{})
+	assert.True(t, errors.IsNotFound(err))
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, types.UID("old-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+	assert.Contains(t, updatedPV.Annotations, "pv.kubernetes.io/bound-by-controller")
+
+	// an unchanged plan is not rendered again
+	plannedAt := metav1.NewTime(updatedReclaim.Status.RestorePlan.PlannedAt.Add(-time.Hour))
+	updatedReclaim.Status.RestorePlan.PlannedAt = plannedAt
+	assert.NoError(t, fakeClient.Status().Update(context.Background(), &updatedReclaim))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	var replannedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &replannedReclaim))
+	assert.True(t, plannedAt.Equal(&replannedReclaim.Status.RestorePlan.PlannedAt))
+}
+
+func TestPVCReclaimController_Reconcile_DryRunRestore_Blocked(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	reclaim.Spec.DryRun = true
+	pv.Status.Phase = corev1.VolumeFailed
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(newPreflightNamespace(), reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, updatedReclaim.Spec.Restore)
+	assert.Empty(t, updatedReclaim.Status.RecoverStatus)
+	if assert.NotNil(t, updatedReclaim.Status.RestorePlan) {
+		assert.False(t, updatedReclaim.Status.RestorePlan.Ready)
+		assert.Contains(t, updatedReclaim.Status.RestorePlan.Blockers, "PV test-pv is not in Released phase")
+		assert.Contains(t, updatedReclaim.Status.RestorePlan.Blockers, "StorageClass: StorageClass standard does not exist")
+	}
+	assert.Contains(t, updatedReclaim.Status.Message, "would be refused")
+}
+
+func TestPVCReclaimController_Reconcile_DryRunRestore_MissingPV(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(pv)
+	reclaim.Spec.Restore = true
+	reclaim.Spec.DryRun = true
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim).WithObjects(newPreflightNamespace(), reclaim).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	if assert.NotNil(t, updatedReclaim.Status.RestorePlan) {
+		assert.True(t, updatedReclaim.Status.RestorePlan.Ready)
+		assert.Contains(t, updatedReclaim.Status.RestorePlan.PersistentVolume, "volumeHandle: vol-1234")
+		assert.Contains(t, updatedReclaim.Status.RestorePlan.PersistentVolume, "name: test-reclaim")
+		assert.Empty(t, updatedReclaim.Status.RestorePlan.PersistentVolumePatch)
+	}
+
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &corev1.PersistentVolume{})
+	assert.True(t, errors.IsNotFound(err))
+}
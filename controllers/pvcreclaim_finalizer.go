@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	protectionFinalizer     = "pvc-reclaim.yibozhuang.me/protection"
+	confirmDeleteAnnotation = "pvc-reclaim.yibozhuang.me/confirm-delete"
+)
+
+// ensureProtectionFinalizer adds the finalizer which keeps the PVCReclaim from
+// being deleted by accident while its PV can still be recovered
+func (r *PVCReclaimController) ensureProtectionFinalizer(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) error {
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	if !controllerutil.AddFinalizer(pvcReclaim, protectionFinalizer) {
+		return nil
+	}
+	return r.client.Patch(ctx, pvcReclaim, patch)
+}
+
+// removeProtectionFinalizer lets the PVCReclaim go once it is no longer needed for recovery
+func (r *PVCReclaimController) removeProtectionFinalizer(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) error {
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	if !controllerutil.RemoveFinalizer(pvcReclaim, protectionFinalizer) {
+		return nil
+	}
+	return r.client.Patch(ctx, pvcReclaim, patch)
+}
+
+// handleDeletion lets a deletion of the PVCReclaim complete when it has been
+// confirmed by annotation or the PV is already gone, otherwise it is held back
+// by the protection finalizer and the reason is reported in status. A confirmed
+// deletion leaves a tombstone on the PV so it is not adopted again. When the
+// namespace of the PVCReclaim is being deleted, the PVCReclaim is stored as a
+// ClusterPVCReclaim before it is let go. pv is nil when the PV no longer exists.
+func (r *PVCReclaimController) handleDeletion(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if pv != nil || canRecreatePersistentVolume(pvcReclaim) {
+		// a PVCReclaim deleted along with its namespace is kept cluster-wide instead
+		namespace, err := r.terminatingNamespace(ctx, pvcReclaim)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if namespace != nil {
+			return ctrl.Result{}, r.storeClusterPVCReclaim(ctx, pvcReclaim, namespace)
+		}
+	}
+
+	var message string
+	switch {
+	case controllerutil.ContainsFinalizer(pvcReclaim, legalHoldFinalizer):
+		// a hold requested after the deletion started was rejected and does not block it
+		message = fmt.Sprintf("Deletion of PVCReclaim is blocked by legal hold placed by %s", pvcReclaim.Status.LegalHold.PlacedBy)
+	case pv == nil || pvcReclaim.Annotations[confirmDeleteAnnotation] == "true":
+		logger.Info("Deletion of PVCReclaim confirmed, removing finalizer", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		if pv != nil {
+			// the PV would otherwise be adopted again by the next sweep
+			if err := r.abandonClaimRecord(ctx, pvcReclaim, pv); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, r.removeProtectionFinalizer(ctx, pvcReclaim)
+	case !controllerutil.ContainsFinalizer(pvcReclaim, protectionFinalizer):
+		return ctrl.Result{}, nil
+	default:
+		message = fmt.Sprintf("Deletion of PVCReclaim is blocked while PV %s can still be recovered, annotate it with %s=true to confirm", pv.Name, confirmDeleteAnnotation)
+	}
+
+	if pvcReclaim.Status.Message == message {
+		return ctrl.Result{}, nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Message = message
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionDeletionBlocked,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             "DeletionNotConfirmed",
+		Message:            message,
+	})
+	return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_DeletionBlockedUntilConfirmed(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, controllerutil.ContainsFinalizer(&updatedReclaim, protectionFinalizer))
+
+	assert.NoError(t, fakeClient.Delete(context.Background(), &updatedReclaim))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionDeletionBlocked))
+	assert.Contains(t, updatedReclaim.Status.Message, confirmDeleteAnnotation)
+
+	updatedReclaim.Annotations = map[string]string{confirmDeleteAnnotation: "true"}
+	assert.NoError(t, fakeClient.Update(context.Background(), &updatedReclaim))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_DeletionAllowedWhenPVGone(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, _ := newReleasedFixtures()
+	now := metav1.Now()
+	reclaim.DeletionTimestamp = &now
+	reclaim.Finalizers = []string{protectionFinalizer}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim).WithObjects(reclaim).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const legalHoldFinalizer = "pvc-reclaim.yibozhuang.me/legal-hold"
+
+// legalHoldActive reports whether the PVCReclaim is under a legal hold
+func legalHoldActive(pvcReclaim *v1alpha1.PVCReclaim) bool {
+	return pvcReclaim.Spec.LegalHold != nil && pvcReclaim.Spec.LegalHold.Enabled
+}
+
+// syncLegalHold records placing and lifting of the legal hold in status and
+// keeps the legal hold finalizer on the PVCReclaim and its PV in step with it.
+// While the hold is active the PV is also forced to the Retain reclaim policy
+// so the backing volume survives even if the PV object is released. A hold
+// requested once the PVCReclaim is being deleted is not placed, as nothing would
+// release it after the deletion, and the LegalHoldRejected condition is raised
+// instead. pv is nil when the PV no longer exists.
+func (r *PVCReclaimController) syncLegalHold(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) error {
+	logger := log.FromContext(ctx)
+	active := legalHoldActive(pvcReclaim)
+
+	if active && pvcReclaim.DeletionTimestamp != nil && !controllerutil.ContainsFinalizer(pvcReclaim, legalHoldFinalizer) {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		changed := meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+			Type:               v1alpha1.ConditionLegalHoldRejected,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: pvcReclaim.Generation,
+			Reason:             "ReclaimDeleting",
+			Message:            fmt.Sprintf("Legal hold requested by %s was not placed as the PVCReclaim is being deleted", pvcReclaim.Spec.LegalHold.RequestedBy),
+		})
+		if !changed {
+			return nil
+		}
+		logger.Info("Not placing legal hold on PVCReclaim being deleted", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), "by", pvcReclaim.Spec.LegalHold.RequestedBy)
+		return r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	if active != (pvcReclaim.Status.LegalHold != nil && pvcReclaim.Status.LegalHold.Active) {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		now := metav1.Now()
+		if pvcReclaim.Status.LegalHold == nil {
+			pvcReclaim.Status.LegalHold = &v1alpha1.LegalHoldStatus{}
+		}
+		hold := pvcReclaim.Status.LegalHold
+		if active {
+			logger.Info("Legal hold placed on PVCReclaim", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), "by", pvcReclaim.Spec.LegalHold.RequestedBy)
+			hold.Active = true
+			hold.PlacedBy = pvcReclaim.Spec.LegalHold.RequestedBy
+			hold.PlacedAt = &now
+			hold.LiftedBy = ""
+			hold.LiftedAt = nil
+			hold.Reason = pvcReclaim.Spec.LegalHold.Reason
+		} else {
+			hold.Active = false
+			hold.LiftedBy = ""
+			hold.Reason = ""
+			if pvcReclaim.Spec.LegalHold != nil {
+				hold.LiftedBy = pvcReclaim.Spec.LegalHold.RequestedBy
+				hold.Reason = pvcReclaim.Spec.LegalHold.Reason
+			}
+			hold.LiftedAt = &now
+			logger.Info("Legal hold lifted from PVCReclaim", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), "by", hold.LiftedBy)
+		}
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return err
+		}
+	}
+
+	if pv != nil {
+		patch := client.MergeFrom(pv.DeepCopy())
+		changed := false
+		if active {
+			changed = controllerutil.AddFinalizer(pv, legalHoldFinalizer)
+			if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+				statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+				pvcReclaim.Status.LegalHold.PersistentVolumeReclaimPolicy = pv.Spec.PersistentVolumeReclaimPolicy
+				if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+					return err
+				}
+				pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+				changed = true
+			}
+		} else if controllerutil.RemoveFinalizer(pv, legalHoldFinalizer) {
+			changed = true
+			if pvcReclaim.Status.LegalHold != nil && pvcReclaim.Status.LegalHold.PersistentVolumeReclaimPolicy != "" {
+				pv.Spec.PersistentVolumeReclaimPolicy = pvcReclaim.Status.LegalHold.PersistentVolumeReclaimPolicy
+			}
+		}
+		if changed {
+			if err := r.client.Patch(ctx, pv, patch); err != nil {
+				return err
+			}
+		}
+		if !active && pvcReclaim.Status.LegalHold != nil && pvcReclaim.Status.LegalHold.PersistentVolumeReclaimPolicy != "" {
+			statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Status.LegalHold.PersistentVolumeReclaimPolicy = ""
+			if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+				return err
+			}
+		}
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	var changed bool
+	if active {
+		changed = controllerutil.AddFinalizer(pvcReclaim, legalHoldFinalizer)
+	} else {
+		changed = controllerutil.RemoveFinalizer(pvcReclaim, legalHoldFinalizer)
+	}
+	if !changed {
+		return nil
+	}
+	return r.client.Patch(ctx, pvcReclaim, patch)
+}
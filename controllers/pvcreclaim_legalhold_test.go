@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_LegalHold_Placed(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.LegalHold = &v1alpha1.LegalHold{
+		Enabled:     true,
+		RequestedBy: "compliance@example.com",
+		Reason:      "case 42",
+	}
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	reclaim.Spec.Purge = true
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.True(t, controllerutil.ContainsFinalizer(&updatedPV, legalHoldFinalizer))
+	assert.Equal(t, corev1.PersistentVolumeReclaimRetain, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, controllerutil.ContainsFinalizer(&updatedReclaim, legalHoldFinalizer))
+	if assert.NotNil(t, updatedReclaim.Status.LegalHold) {
+		assert.True(t, updatedReclaim.Status.LegalHold.Active)
+		assert.Equal(t, "compliance@example.com", updatedReclaim.Status.LegalHold.PlacedBy)
+		assert.NotNil(t, updatedReclaim.Status.LegalHold.PlacedAt)
+		assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedReclaim.Status.LegalHold.PersistentVolumeReclaimPolicy)
+	}
+	// purge is suspended while the hold is in place
+	assert.True(t, updatedReclaim.Spec.Purge)
+	assert.NotEqual(t, v1alpha1.PurgeInProgress, updatedReclaim.Status.RecoverStatus)
+}
+
+func TestPVCReclaimController_Reconcile_LegalHold_BlocksDeletion(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.LegalHold = &v1alpha1.LegalHold{
+		Enabled:     true,
+		RequestedBy: "compliance@example.com",
+		Reason:      "case 42",
+	}
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.NoError(t, fakeClient.Delete(context.Background(), &updatedReclaim))
+
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.NotNil(t, updatedReclaim.DeletionTimestamp)
+	assert.Contains(t, updatedReclaim.Status.Message, "blocked by legal hold")
+
+	// lifting the hold lets the confirmed deletion complete
+	updatedReclaim.Spec.LegalHold = &v1alpha1.LegalHold{Enabled: false, RequestedBy: "legal@example.com"}
+	updatedReclaim.Annotations = map[string]string{confirmDeleteAnnotation: "true"}
+	assert.NoError(t, fakeClient.Update(context.Background(), &updatedReclaim))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.False(t, controllerutil.ContainsFinalizer(&updatedPV, legalHoldFinalizer))
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+}
+
+func TestPVCReclaimController_Reconcile_LegalHold_RejectedWhileDeleting(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	now := metav1.Now()
+	reclaim, pv := newReleasedFixtures()
+	reclaim.DeletionTimestamp = &now
+	reclaim.Finalizers = []string{protectionFinalizer}
+	reclaim.Spec.LegalHold = &v1alpha1.LegalHold{
+		Enabled:     true,
+		RequestedBy: "compliance@example.com",
+	}
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, controllerutil.ContainsFinalizer(&updatedReclaim, legalHoldFinalizer))
+	assert.Nil(t, updatedReclaim.Status.LegalHold)
+	condition := meta.FindStatusCondition(updatedReclaim.Status.Conditions, v1alpha1.ConditionLegalHoldRejected)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, metav1.ConditionTrue, condition.Status)
+		assert.Equal(t, "ReclaimDeleting", condition.Reason)
+	}
+	// the deletion is only held back until it is confirmed
+	assert.NotContains(t, updatedReclaim.Status.Message, "legal hold")
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.False(t, controllerutil.ContainsFinalizer(&updatedPV, legalHoldFinalizer))
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+}
+
+func TestPVCReclaimController_Reconcile_LegalHold_Lifted(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.LegalHold = &v1alpha1.LegalHold{
+		Enabled:     true,
+		RequestedBy: "compliance@example.com",
+		Reason:      "case 42",
+	}
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	updatedReclaim.Spec.LegalHold = &v1alpha1.LegalHold{Enabled: false, RequestedBy: "legal@example.com", Reason: "case closed"}
+	assert.NoError(t, fakeClient.Update(context.Background(), &updatedReclaim))
+
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, controllerutil.ContainsFinalizer(&updatedReclaim, legalHoldFinalizer))
+	if assert.NotNil(t, updatedReclaim.Status.LegalHold) {
+		assert.False(t, updatedReclaim.Status.LegalHold.Active)
+		assert.Equal(t, "compliance@example.com", updatedReclaim.Status.LegalHold.PlacedBy)
+		assert.Equal(t, "legal@example.com", updatedReclaim.Status.LegalHold.LiftedBy)
+		assert.NotNil(t, updatedReclaim.Status.LegalHold.LiftedAt)
+		assert.Equal(t, "case closed", updatedReclaim.Status.LegalHold.Reason)
+	}
+}
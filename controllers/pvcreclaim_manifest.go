@@ -0,0 +1,380 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// DefaultContentManifestImage is the image of the Job scanning a released PV
+	DefaultContentManifestImage = "busybox:1.36"
+	// contentManifestLabel marks the scratch PVC and the Job of a scan with the name of its PVCReclaim
+	contentManifestLabel = "pvc-reclaim.yibozhuang.me/content-manifest"
+	// contentManifestPollInterval is how often a running scan is checked on
+	contentManifestPollInterval = 10 * time.Second
+	// contentManifestMaxSize keeps the ConfigMap below the 1MiB limit of objects, checksums beyond it are left out
+	contentManifestMaxSize = 900 * 1024
+	// contentManifestSummaryKey and contentManifestChecksumsKey are the keys of the manifest in the ConfigMap
+	contentManifestSummaryKey   = "summary.yaml"
+	contentManifestChecksumsKey = "sha256sums"
+)
+
+// contentManifestScript prints one line per fact about the content of /data,
+// which is parsed from the logs of the pod:
+//
+//	sha256 <sum>  <path>
+//	dir <bytes> <top-level directory>
+//	files <count>
+//	bytes <total>
+//
+// The summary comes last, so it survives a rotation of the container log by a
+// long list of checksums, which only the tail of is then read back.
+const contentManifestScript = `set -e
+cd /data
+if [ "$CHECKSUMS" = "true" ]; then
+  find . -type f -exec sha256sum {} + | sed 's/^/sha256 /'
+fi
+for d in * .[!.]*; do
+  if [ -d "$d" ] && [ ! -L "$d" ]; then
+    echo "dir $(find "$d" -type f -exec stat -c %s {} + | awk '{s+=$1} END {print s+0}') $d"
+  fi
+done
+echo "files $(find . -type f | wc -l)"
+echo "bytes $(find . -type f -exec stat -c %s {} + | awk '{s+=$1} END {print s+0}')"
+`
+
+//+kubebuilder:rbac:groups=``,resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups=``,resources=configmaps,verbs=get;create;update
+
+// PodLogReader reads the logs of a container, which the controller-runtime client cannot
+type PodLogReader interface {
+	ReadLogs(ctx context.Context, namespace, name, container string) ([]byte, error)
+}
+
+type clientsetPodLogReader struct {
+	clientset kubernetes.Interface
+}
+
+// NewPodLogReader returns a PodLogReader reading logs through the clientset
+func NewPodLogReader(clientset kubernetes.Interface) PodLogReader {
+	return &clientsetPodLogReader{clientset: clientset}
+}
+
+func (r *clientsetPodLogReader) ReadLogs(ctx context.Context, namespace, name, container string) ([]byte, error) {
+	return r.clientset.CoreV1().Pods(namespace).GetLogs(name, &corev1.PodLogOptions{Container: container}).DoRaw(ctx)
+}
+
+// contentSummary is the summary of a scan stored in the ConfigMap
+type contentSummary struct {
+	TotalBytes  int64            `json:"totalBytes"`
+	FileCount   int64            `json:"fileCount"`
+	Directories map[string]int64 `json:"directories,omitempty"`
+}
+
+// parseContentManifest parses the output of contentManifestScript into the
+// summary and the checksum manifest in sha256sum format. Output without the
+// file count and total bytes, e.g. cut short, is an error.
+func parseContentManifest(output []byte) (*contentSummary, string, error) {
+	summary := &contentSummary{}
+	var checksums strings.Builder
+	var counted, sized bool
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		kind, rest, _ := strings.Cut(scanner.Text(), " ")
+		var err error
+		switch kind {
+		case "files":
+			summary.FileCount, err = strconv.ParseInt(rest, 10, 64)
+			counted = true
+		case "bytes":
+			summary.TotalBytes, err = strconv.ParseInt(rest, 10, 64)
+			sized = true
+		case "dir":
+			size, name, _ := strings.Cut(rest, " ")
+			var n int64
+			if n, err = strconv.ParseInt(size, 10, 64); err == nil {
+				if summary.Directories == nil {
+					summary.Directories = map[string]int64{}
+				}
+				summary.Directories[name] = n
+			}
+		case "sha256":
+			checksums.WriteString(rest + "\n")
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("unexpected line %q: %w", scanner.Text(), err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", err
+	}
+	if !counted || !sized {
+		return nil, "", fmt.Errorf("file count or total bytes missing")
+	}
+	return summary, checksums.String(), nil
+}
+
+// contentManifestName returns the name of the scratch PVC, the Job and the ConfigMap of the scan of the PVCReclaim
+func contentManifestName(pvcReclaim *v1alpha1.PVCReclaim) string {
+	return pvcReclaim.Name + "-content"
+}
+
+// contentManifestJob returns the Job mounting the scratch PVC read-only and listing its content
+func (r *PVCReclaimController) contentManifestJob(pvcReclaim *v1alpha1.PVCReclaim, pvc *corev1.PersistentVolumeClaim) *batchv1.Job {
+	labels := map[string]string{contentManifestLabel: pvcReclaim.Name}
+	backoffLimit := int32(1)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      contentManifestName(pvcReclaim),
+			Namespace: pvcReclaim.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "scan",
+						Image:   r.contentManifestImage,
+						Command: []string{"sh", "-c", contentManifestScript},
+						Env:     []corev1.EnvVar{{Name: "CHECKSUMS", Value: strconv.FormatBool(r.contentManifestChecksums)}},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "data", MountPath: "/data", ReadOnly: true},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name, ReadOnly: true}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// startContentScan binds the Released PV to a scratch PVC and creates the Job scanning it
+func (r *PVCReclaimController) startContentScan(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	name := contentManifestName(pvcReclaim)
+	pvc := scratchClaim(name, pvcReclaim.Namespace, map[string]string{contentManifestLabel: pvcReclaim.Name}, pv)
+	job := r.contentManifestJob(pvcReclaim, pvc)
+	// the scratch PVC and Job are garbage collected with the PVCReclaim
+	for _, object := range []client.Object{pvc, job} {
+		if err := controllerutil.SetControllerReference(pvcReclaim, object, r.client.Scheme()); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	log.FromContext(ctx).Info("Scanning content of released PV", "pv", pv.Name, "job", fmt.Sprintf("%s/%s", job.Namespace, job.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := r.client.Create(ctx, pvc); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+	patch := client.MergeFrom(pv.DeepCopy())
+	bindPersistentVolume(pv, pvc, PersistentVolumeAnnotationPolicy{})
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.ContentManifest = &v1alpha1.ContentManifestStatus{
+		Phase:   v1alpha1.ContentManifestScanning,
+		JobName: job.Name,
+		Message: "Scan Job created",
+	}
+	if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: contentManifestPollInterval}, nil
+}
+
+// contentScanActive reports whether the PV is bound to the scratch PVC of a scan
+func contentScanActive(pvcReclaim *v1alpha1.PVCReclaim) bool {
+	status := pvcReclaim.Status.ContentManifest
+	return status != nil && (status.Phase == v1alpha1.ContentManifestScanning || status.Phase == v1alpha1.ContentManifestReleasing)
+}
+
+// scanContent records the manifest once the scan Job completes, then returns the PV to its claimRef
+func (r *PVCReclaimController) scanContent(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	if pvcReclaim.Status.ContentManifest.Phase == v1alpha1.ContentManifestReleasing {
+		return r.releaseContentScan(ctx, pvcReclaim, pv)
+	}
+	status := pvcReclaim.Status.ContentManifest.DeepCopy()
+
+	var job batchv1.Job
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: pvcReclaim.Namespace, Name: status.JobName}, &job)
+	switch {
+	case errors.IsNotFound(err):
+		status.Message = fmt.Sprintf("scan Job %s was deleted", status.JobName)
+	case err != nil:
+		return ctrl.Result{}, err
+	case jobConditionTrue(&job, batchv1.JobFailed):
+		status.Message = fmt.Sprintf("scan Job %s failed", status.JobName)
+	case jobConditionTrue(&job, batchv1.JobComplete):
+		if err := r.recordContentManifest(ctx, pvcReclaim, &job, status); err != nil {
+			return ctrl.Result{}, err
+		}
+	default:
+		return ctrl.Result{RequeueAfter: contentManifestPollInterval}, nil
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	status.Phase = v1alpha1.ContentManifestReleasing
+	pvcReclaim.Status.ContentManifest = status
+	if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return r.releaseContentScan(ctx, pvcReclaim, pv)
+}
+
+// recordContentManifest parses the logs of the succeeded scan pod into the ConfigMap and the status.
+// A manifest which cannot be read is recorded as a message rather than retried.
+func (r *PVCReclaimController) recordContentManifest(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, job *batchv1.Job, status *v1alpha1.ContentManifestStatus) error {
+	var pods corev1.PodList
+	if err := r.client.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{contentManifestLabel: pvcReclaim.Name}); err != nil {
+		return err
+	}
+	podName := ""
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded {
+			podName = pod.Name
+		}
+	}
+	if podName == "" {
+		status.Message = fmt.Sprintf("no succeeded pod of scan Job %s is left to read", job.Name)
+		return nil
+	}
+	output, err := r.podLogs.ReadLogs(ctx, job.Namespace, podName, "scan")
+	if err != nil {
+		return err
+	}
+	summary, checksums, err := parseContentManifest(output)
+	if err != nil {
+		status.Message = fmt.Sprintf("output of scan pod %s cannot be parsed: %v", podName, err)
+		return nil
+	}
+	rendered, err := yaml.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      contentManifestName(pvcReclaim),
+			Namespace: pvcReclaim.Namespace,
+		},
+	}
+	status.Message = fmt.Sprintf("%d files, %d bytes", summary.FileCount, summary.TotalBytes)
+	_, err = controllerutil.CreateOrUpdate(ctx, r.client, configMap, func() error {
+		configMap.Data = map[string]string{contentManifestSummaryKey: string(rendered)}
+		status.Checksums = false
+		if checksums != "" {
+			if int64(strings.Count(checksums, "\n")) < summary.FileCount {
+				// the head of the log was rotated away
+				status.Message += ", checksums left out as the scan log was truncated"
+			} else if len(rendered)+len(checksums) > contentManifestMaxSize {
+				status.Message += ", checksums left out as they exceed the size of a ConfigMap"
+			} else {
+				configMap.Data[contentManifestChecksumsKey] = checksums
+				status.Checksums = true
+			}
+		}
+		return controllerutil.SetControllerReference(pvcReclaim, configMap, r.client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+	status.ConfigMapName = configMap.Name
+	status.TotalBytes = summary.TotalBytes
+	status.FileCount = summary.FileCount
+	return nil
+}
+
+// releaseContentScan deletes the scan Job and the scratch PVC, then returns the PV to its claimRef
+func (r *PVCReclaimController) releaseContentScan(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	name := contentManifestName(pvcReclaim)
+	objects := []client.Object{
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: pvcReclaim.Status.ContentManifest.JobName}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: name}},
+	}
+	if leftover, err := r.deleteObjects(ctx, objects...); err != nil || leftover {
+		return ctrl.Result{RequeueAfter: contentManifestPollInterval}, err
+	}
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.ClaimRef = reservedClaimRef(pvcReclaim)
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	now := metav1.Now()
+	pvcReclaim.Status.ContentManifest.Phase = v1alpha1.ContentManifestFailed
+	if pvcReclaim.Status.ContentManifest.ConfigMapName != "" {
+		pvcReclaim.Status.ContentManifest.Phase = v1alpha1.ContentManifestCompleted
+	}
+	pvcReclaim.Status.ContentManifest.CompletionTime = &now
+	return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, statusPatch)
+}
+
+// deleteObjects deletes the objects along with their dependents, e.g. the pods
+// of a Job. It reports whether any of them still exists.
+func (r *PVCReclaimController) deleteObjects(ctx context.Context, objects ...client.Object) (bool, error) {
+	leftover := false
+	deletePolicy := metav1.DeletePropagationBackground
+	for _, object := range objects {
+		if err := r.client.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		leftover = true
+		if object.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := r.client.Delete(ctx, object, &client.DeleteOptions{PropagationPolicy: &deletePolicy}); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	}
+	return leftover, nil
+}
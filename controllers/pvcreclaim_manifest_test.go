@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testContentManifestOutput = `sha256 e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  ./pgdata/PG_VERSION
+sha256 8b2c2f0a4cc0b6e7d4c6f1c8ecf5b3c1d5b2a3f0f1e5d1c9b0a7e6d5c4b3a291  ./pgdata/base/1
+sha256 0f3e2d1c0b9a8f7e6d5c4b3a29180706f5e4d3c2b1a09f8e7d6c5b4a39281706  ./README
+dir 1024 pgdata
+dir 0 lost+found
+files 3
+bytes 1536
+`
+
+type stubPodLogReader struct {
+	output []byte
+}
+
+func (r *stubPodLogReader) ReadLogs(_ context.Context, _, _, _ string) ([]byte, error) {
+	return r.output, nil
+}
+
+func TestParseContentManifest(t *testing.T) {
+	summary, checksums, err := parseContentManifest([]byte(testContentManifestOutput))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), summary.FileCount)
+	assert.Equal(t, int64(1536), summary.TotalBytes)
+	assert.Equal(t, map[string]int64{"pgdata": 1024, "lost+found": 0}, summary.Directories)
+	assert.Equal(t, 3, strings.Count(checksums, "\n"))
+	assert.True(t, strings.HasPrefix(checksums, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  ./pgdata/PG_VERSION\n"))
+
+	_, _, err = parseContentManifest([]byte("files many\n"))
+	assert.Error(t, err)
+
+	// the summary is missing from output cut short
+	_, _, err = parseContentManifest([]byte("sha256 e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  ./a\n"))
+	assert.Error(t, err)
+}
+
+func TestPVCReclaimController_Reconcile_ContentManifest(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv, &batchv1.Job{}).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient, WithContentManifest(&stubPodLogReader{output: []byte(testContentManifestOutput)}), WithContentManifestChecksums(true))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	name := types.NamespacedName{Name: "test-reclaim-content", Namespace: "default"}
+	var scratch corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(ctx, name, &scratch))
+	var job batchv1.Job
+	assert.NoError(t, fakeClient.Get(ctx, name, &job))
+	assert.True(t, job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+	assert.Equal(t, "true", job.Spec.Template.Spec.Containers[0].Env[0].Value)
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, scratch.Name, updatedPV.Spec.ClaimRef.Name)
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	assert.NoError(t, fakeClient.Status().Update(ctx, &job))
+	assert.NoError(t, fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-reclaim-content-abcde", Namespace: "default", Labels: map[string]string{contentManifestLabel: "test-reclaim"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+	}))
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	var configMap corev1.ConfigMap
+	assert.NoError(t, fakeClient.Get(ctx, name, &configMap))
+	assert.Contains(t, configMap.Data[contentManifestSummaryKey], "totalBytes: 1536")
+	assert.Contains(t, configMap.Data[contentManifestChecksumsKey], "./pgdata/PG_VERSION")
+	err = fakeClient.Get(ctx, name, &scratch)
+	assert.True(t, errors.IsNotFound(err))
+
+	// the PV is returned to its claimRef once the scratch PVC is gone
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+	assert.Equal(t, types.UID("old-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	manifest := updatedReclaim.Status.ContentManifest
+	assert.Equal(t, v1alpha1.ContentManifestCompleted, manifest.Phase)
+	assert.Equal(t, configMap.Name, manifest.ConfigMapName)
+	assert.Equal(t, int64(3), manifest.FileCount)
+	assert.True(t, manifest.Checksums)
+
+	// the PV is scanned once
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	err = fakeClient.Get(ctx, name, &job)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_ContentManifest_RotatedLog(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	// only the tail of the rotated log is left
+	output := "sha256 0f3e2d1c0b9a8f7e6d5c4b3a29180706f5e4d3c2b1a09f8e7d6c5b4a39281706  ./README\nfiles 3\nbytes 1536\n"
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv, &batchv1.Job{}).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient, WithContentManifest(&stubPodLogReader{output: []byte(output)}), WithContentManifestChecksums(true))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	name := types.NamespacedName{Name: "test-reclaim-content", Namespace: "default"}
+	var job batchv1.Job
+	assert.NoError(t, fakeClient.Get(ctx, name, &job))
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	assert.NoError(t, fakeClient.Status().Update(ctx, &job))
+	assert.NoError(t, fakeClient.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-reclaim-content-abcde", Namespace: "default", Labels: map[string]string{contentManifestLabel: "test-reclaim"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+	}))
+	for i := 0; i < 2; i++ {
+		_, err = controller.Reconcile(ctx, req)
+		assert.NoError(t, err)
+	}
+
+	var configMap corev1.ConfigMap
+	assert.NoError(t, fakeClient.Get(ctx, name, &configMap))
+	assert.NotContains(t, configMap.Data, contentManifestChecksumsKey)
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	manifest := updatedReclaim.Status.ContentManifest
+	assert.Equal(t, v1alpha1.ContentManifestCompleted, manifest.Phase)
+	assert.Equal(t, int64(3), manifest.FileCount)
+	assert.Equal(t, int64(1536), manifest.TotalBytes)
+	assert.False(t, manifest.Checksums)
+	assert.Contains(t, manifest.Message, "scan log was truncated")
+}
@@ -0,0 +1,534 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// DefaultMigrationImage is the image running rsync in the copy Job of a migration
+	DefaultMigrationImage = "instrumentisto/rsync-ssh:alpine"
+	// DefaultMigrationStagingNamespace is where migrations copy the data unless the PVCReclaim names another namespace
+	DefaultMigrationStagingNamespace = "pvc-reclaim-system"
+	// migrationLabel marks the staging PVCs and the copy Job of a migration with its name
+	migrationLabel = "pvc-reclaim.yibozhuang.me/migration"
+	// migrationPollInterval is how often a running migration is checked on
+	migrationPollInterval = 10 * time.Second
+	// migrationBackoffLimit is how many times the copy Job retries a failed copy
+	migrationBackoffLimit int32 = 2
+)
+
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=``,resources=pods,verbs=get;list;watch
+
+// migrationName returns the name of the copy Job of the PVCReclaim, which
+// prefixes its staging PVCs. It is derived from the namespace and name of the
+// PVCReclaim, so migrations of PVCReclaims in different namespaces do not
+// collide in the staging namespace.
+func migrationName(pvcReclaim *v1alpha1.PVCReclaim) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(pvcReclaim.Namespace + "/" + pvcReclaim.Name))
+	return fmt.Sprintf("migrate-%08x", h.Sum32())
+}
+
+// migrationActive reports whether a migration of the PVCReclaim is under way
+func migrationActive(pvcReclaim *v1alpha1.PVCReclaim) bool {
+	status := pvcReclaim.Status.Migration
+	return status != nil && (status.Phase == v1alpha1.MigrationCopying || status.Phase == v1alpha1.MigrationHandingOver)
+}
+
+// migrate restores the PVC into another StorageClass. The released PV is bound
+// to a staging PVC, a staging PVC is provisioned in the new StorageClass and a
+// Job copies the data between them. Once the copy completes the provisioned PV
+// is handed over to the restored PVC and the staging PVCs are removed.
+func (r *PVCReclaimController) migrate(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	if !migrationActive(pvcReclaim) {
+		return r.startMigration(ctx, pvcReclaim, pv)
+	}
+	if pvcReclaim.Status.Migration.Phase == v1alpha1.MigrationCopying {
+		return r.waitForCopy(ctx, pvcReclaim)
+	}
+	return r.handOverMigration(ctx, pvcReclaim, pv)
+}
+
+// migrationClaims returns the staging PVCs the data is copied from and into
+func (r *PVCReclaimController) migrationClaims(pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, stagingNamespace string) (*corev1.PersistentVolumeClaim, *corev1.PersistentVolumeClaim) {
+	name := migrationName(pvcReclaim)
+	labels := map[string]string{migrationLabel: name}
+
+	source := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-source",
+			Namespace: stagingNamespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName:       pv.Name,
+			StorageClassName: &pv.Spec.StorageClassName,
+			AccessModes:      pv.Spec.AccessModes,
+			VolumeMode:       pv.Spec.VolumeMode,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: pv.Spec.Capacity[corev1.ResourceStorage],
+				},
+			},
+		},
+	}
+
+	storageClassName := pvcReclaim.Spec.MigrateTo.StorageClassName
+	target := restoredPersistentVolumeClaim(pvcReclaim, r.sanitizer)
+	target.ObjectMeta = metav1.ObjectMeta{
+		Name:      name + "-target",
+		Namespace: stagingNamespace,
+		Labels:    labels,
+	}
+	target.Spec.VolumeName = ""
+	target.Spec.StorageClassName = &storageClassName
+	if _, found := target.Spec.Resources.Requests[corev1.ResourceStorage]; !found {
+		if target.Spec.Resources.Requests == nil {
+			target.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		target.Spec.Resources.Requests[corev1.ResourceStorage] = pv.Spec.Capacity[corev1.ResourceStorage]
+	}
+	return source, target
+}
+
+// migrationJob returns the Job copying the data between the staging PVCs
+func (r *PVCReclaimController) migrationJob(source, target *corev1.PersistentVolumeClaim) *batchv1.Job {
+	name := source.Labels[migrationLabel]
+	labels := map[string]string{migrationLabel: name}
+	backoffLimit := migrationBackoffLimit
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: source.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "copy",
+						Image:   r.migrationImage,
+						Command: []string{"rsync", "-aHAX", "--info=progress2", "/source/", "/target/"},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "source", MountPath: "/source", ReadOnly: true},
+							{Name: "target", MountPath: "/target"},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "source", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: source.Name, ReadOnly: true}}},
+						{Name: "target", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: target.Name}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// migrationBlockers returns the reasons the migration of the PV cannot start
+func (r *PVCReclaimController) migrationBlockers(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, stagingNamespace string) ([]string, error) {
+	// the PVCReclaim has to be restorable before its PV is bound to a staging PVC
+	blockers, err := r.runChecks(ctx, pvcReclaim, pv, migrationPreflightChecks())
+	if err != nil {
+		return nil, err
+	}
+	if !restorable(pvcReclaim, pv) {
+		blockers = append(blockers, fmt.Sprintf("PV %s is not in Released phase", pv.Name))
+	}
+	if pv.Spec.VolumeMode != nil && *pv.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		blockers = append(blockers, fmt.Sprintf("PV %s is a block volume, only filesystem volumes can be copied", pv.Name))
+	}
+
+	storageClassName := pvcReclaim.Spec.MigrateTo.StorageClassName
+	var storageClass storagev1.StorageClass
+	if err := r.client.Get(ctx, types.NamespacedName{Name: storageClassName}, &storageClass); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		blockers = append(blockers, fmt.Sprintf("StorageClass %s does not exist", storageClassName))
+	}
+
+	var namespace corev1.Namespace
+	if err := r.client.Get(ctx, types.NamespacedName{Name: stagingNamespace}, &namespace); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		blockers = append(blockers, fmt.Sprintf("staging namespace %s does not exist", stagingNamespace))
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: pvcReclaim.Name}, &pvc)
+	if err == nil {
+		blockers = append(blockers, fmt.Sprintf("PVC %s/%s already exists", pvc.Namespace, pvc.Name))
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return blockers, nil
+}
+
+// startMigration creates the staging PVCs and the copy Job once nothing is left of a previous migration
+func (r *PVCReclaimController) startMigration(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	stagingNamespace := pvcReclaim.Spec.MigrateTo.StagingNamespace
+	if stagingNamespace == "" {
+		stagingNamespace = r.migrationStagingNamespace
+	}
+	blockers, err := r.migrationBlockers(ctx, pvcReclaim, pv, stagingNamespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if pvcReclaim.Spec.DryRun {
+		restored := restoredPersistentVolumeClaim(pvcReclaim, r.sanitizer)
+		restored.Spec.VolumeName = ""
+		restored.Spec.StorageClassName = &pvcReclaim.Spec.MigrateTo.StorageClassName
+		manifest, err := renderManifest(restored, "PersistentVolumeClaim")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.recordRestorePlan(ctx, pvcReclaim, &v1alpha1.RestorePlan{
+			Ready:                 len(blockers) == 0,
+			Blockers:              blockers,
+			PersistentVolumeClaim: manifest,
+		})
+	}
+
+	if len(blockers) > 0 {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("Migration cannot start: %s", strings.Join(blockers, "; "))
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	source, target := r.migrationClaims(pvcReclaim, pv, stagingNamespace)
+	job := r.migrationJob(source, target)
+
+	// the staging PVCs and Job of a failed migration have to be gone first
+	leftover, err := r.deleteMigrationObjects(ctx, stagingNamespace, job.Name, true)
+	if err != nil || leftover {
+		return ctrl.Result{RequeueAfter: migrationPollInterval}, err
+	}
+
+	logger.Info("Starting migration into new StorageClass", "pv", pv.Name, "storageClass", *target.Spec.StorageClassName, "job", fmt.Sprintf("%s/%s", job.Namespace, job.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	for _, claim := range []*corev1.PersistentVolumeClaim{source, target} {
+		if err := r.client.Create(ctx, claim); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	patch := client.MergeFrom(pv.DeepCopy())
+	bindPersistentVolume(pv, source, PersistentVolumeAnnotationPolicy{})
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Create(ctx, job); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	now := metav1.Now()
+	pvcReclaim.Status.Migration = &v1alpha1.MigrationStatus{
+		Phase:            v1alpha1.MigrationCopying,
+		StorageClassName: *target.Spec.StorageClassName,
+		StagingNamespace: stagingNamespace,
+		SourceClaimName:  source.Name,
+		TargetClaimName:  target.Name,
+		JobName:          job.Name,
+		Message:          "Copy Job created",
+		StartTime:        &now,
+	}
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
+	pvcReclaim.Status.Reason = ""
+	pvcReclaim.Status.Message = fmt.Sprintf("Migrating PV %s into StorageClass %s", pv.Name, *target.Spec.StorageClassName)
+	if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: migrationPollInterval}, nil
+}
+
+// waitForCopy follows the copy Job, reporting its attempts and the exit code of its last pod
+func (r *PVCReclaimController) waitForCopy(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) (ctrl.Result, error) {
+	status := pvcReclaim.Status.Migration
+
+	var job batchv1.Job
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: status.StagingNamespace, Name: status.JobName}, &job); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.failMigration(ctx, pvcReclaim, fmt.Sprintf("copy Job %s/%s was deleted", status.StagingNamespace, status.JobName))
+		}
+		return ctrl.Result{}, err
+	}
+
+	updated := status.DeepCopy()
+	updated.Attempts = job.Status.Succeeded + job.Status.Failed
+	exitCode, err := r.copyExitCode(ctx, &job)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if exitCode != nil {
+		updated.ExitCode = exitCode
+	}
+	var target corev1.PersistentVolumeClaim
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: status.StagingNamespace, Name: status.TargetClaimName}, &target); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.failMigration(ctx, pvcReclaim, fmt.Sprintf("staging PVC %s/%s was deleted", status.StagingNamespace, status.TargetClaimName))
+		}
+		return ctrl.Result{}, err
+	}
+	updated.TargetPersistentVolumeName = target.Spec.VolumeName
+
+	requeue := ctrl.Result{RequeueAfter: migrationPollInterval}
+	switch {
+	case jobConditionTrue(&job, batchv1.JobComplete):
+		updated.Phase = v1alpha1.MigrationHandingOver
+		updated.Message = "Data copied, handing over the new PV"
+		requeue = ctrl.Result{Requeue: true}
+	case jobConditionTrue(&job, batchv1.JobFailed):
+		message := fmt.Sprintf("copy Job %s/%s failed after %d attempts", job.Namespace, job.Name, updated.Attempts)
+		if updated.ExitCode != nil {
+			message = fmt.Sprintf("%s, last exit code %d", message, *updated.ExitCode)
+		}
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.Migration = updated
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.failMigration(ctx, pvcReclaim, message)
+	case job.Status.Active > 0:
+		updated.Message = fmt.Sprintf("Copying, %d of %d retries used", job.Status.Failed, migrationBackoffLimit)
+	default:
+		updated.Message = "Waiting for the copy pod to start"
+	}
+
+	if updated.Phase != status.Phase || updated.Message != status.Message || updated.Attempts != status.Attempts ||
+		updated.TargetPersistentVolumeName != status.TargetPersistentVolumeName || !equalExitCode(updated.ExitCode, status.ExitCode) {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.Migration = updated
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return requeue, nil
+}
+
+// copyExitCode returns the exit code of the copy container of the pod of the Job which terminated last, nil if none did
+func (r *PVCReclaimController) copyExitCode(ctx context.Context, job *batchv1.Job) (*int32, error) {
+	var pods corev1.PodList
+	if err := r.client.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{migrationLabel: job.Labels[migrationLabel]}); err != nil {
+		return nil, err
+	}
+	var exitCode *int32
+	var finishedAt time.Time
+	for i := range pods.Items {
+		for _, containerStatus := range pods.Items[i].Status.ContainerStatuses {
+			terminated := containerStatus.State.Terminated
+			if containerStatus.Name != "copy" || terminated == nil || terminated.FinishedAt.Time.Before(finishedAt) {
+				continue
+			}
+			code := terminated.ExitCode
+			exitCode, finishedAt = &code, terminated.FinishedAt.Time
+		}
+	}
+	return exitCode, nil
+}
+
+// handOverMigration moves the PV the data was copied into from the staging
+// PVC to the restored PVC. It is kept by forcing its reclaim policy to Retain
+// while the staging PVC is deleted, then bound to the restored PVC and given
+// back its reclaim policy. The released PV is reserved for the PVCReclaim
+// again, which is deleted as the restore is complete.
+func (r *PVCReclaimController) handOverMigration(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	status := pvcReclaim.Status.Migration
+
+	var targetPV corev1.PersistentVolume
+	if err := r.client.Get(ctx, types.NamespacedName{Name: status.TargetPersistentVolumeName}, &targetPV); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var target corev1.PersistentVolumeClaim
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: status.StagingNamespace, Name: status.TargetClaimName}, &target)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil {
+		if targetPV.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+			statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Status.Migration.TargetReclaimPolicy = targetPV.Spec.PersistentVolumeReclaimPolicy
+			if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+				return ctrl.Result{}, err
+			}
+			patch := client.MergeFrom(targetPV.DeepCopy())
+			targetPV.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+			if err := r.client.Patch(ctx, &targetPV, patch); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if _, err := r.deleteMigrationObjects(ctx, status.StagingNamespace, status.JobName, false); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: migrationPollInterval}, nil
+	}
+
+	restored := restoredPersistentVolumeClaim(pvcReclaim, r.sanitizer)
+	restored.Spec.StorageClassName = &status.StorageClassName
+	restored.Spec.VolumeName = targetPV.Name
+	if err := r.client.Create(ctx, restored); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: restored.Namespace, Name: restored.Name}, restored); err != nil {
+		return ctrl.Result{}, err
+	}
+	patch := client.MergeFrom(targetPV.DeepCopy())
+	bindPersistentVolume(&targetPV, restored, PersistentVolumeAnnotationPolicy{})
+	if status.TargetReclaimPolicy != "" {
+		targetPV.Spec.PersistentVolumeReclaimPolicy = status.TargetReclaimPolicy
+	}
+	if err := r.client.Patch(ctx, &targetPV, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the released PV goes back to being reserved for the deleted PVC
+	patch = client.MergeFrom(pv.DeepCopy())
+	pv.Spec.ClaimRef = reservedClaimRef(pvcReclaim)
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	if _, err := r.deleteMigrationObjects(ctx, status.StagingNamespace, status.JobName, true); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	now := metav1.Now()
+	pvcReclaim.Status.Migration.Phase = v1alpha1.MigrationSucceeded
+	pvcReclaim.Status.Migration.Message = fmt.Sprintf("Data copied into PV %s bound to PVC %s/%s", targetPV.Name, restored.Namespace, restored.Name)
+	pvcReclaim.Status.Migration.CompletionTime = &now
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoverySuccess
+	pvcReclaim.Status.Reason = ""
+	pvcReclaim.Status.Message = fmt.Sprintf("Successfully migrated PVC %s/%s into StorageClass %s, PV %s is left Released", restored.Namespace, restored.Name, status.StorageClassName, pv.Name)
+	if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+		return ctrl.Result{}, err
+	}
+	specPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.Restore = false
+	if err := r.client.Patch(ctx, pvcReclaim, specPatch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Deleting PVCReclaim after successfully migrating PVC", "pv", pv.Name, "targetPV", targetPV.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	return ctrl.Result{}, r.deletePVCReclaim(ctx, pvcReclaim)
+}
+
+// failMigration ends a migration which cannot complete. The staging PVCs are
+// deleted, so the released PV is reserved for the PVCReclaim again, while the
+// copy Job is kept for its logs until the next migration.
+func (r *PVCReclaimController) failMigration(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, reason string) error {
+	status := pvcReclaim.Status.Migration
+	log.FromContext(ctx).Info("Migration failed", "reason", reason, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if _, err := r.deleteMigrationObjects(ctx, status.StagingNamespace, status.JobName, false); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.Restore = false
+	if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+		return err
+	}
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	now := metav1.Now()
+	pvcReclaim.Status.Migration.Phase = v1alpha1.MigrationFailed
+	pvcReclaim.Status.Migration.Message = reason
+	pvcReclaim.Status.Migration.CompletionTime = &now
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+	pvcReclaim.Status.Reason = fmt.Sprintf("Migration failed: %s", reason)
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// deleteMigrationObjects deletes the staging PVCs of the migration, and its
+// copy Job when withJob is set. It reports whether any of them still exists.
+func (r *PVCReclaimController) deleteMigrationObjects(ctx context.Context, stagingNamespace, name string, withJob bool) (bool, error) {
+	objects := []client.Object{
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: stagingNamespace, Name: name + "-source"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: stagingNamespace, Name: name + "-target"}},
+	}
+	if withJob {
+		objects = append(objects, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: stagingNamespace, Name: name}})
+	}
+	leftover := false
+	deletePolicy := metav1.DeletePropagationBackground
+	for _, object := range objects {
+		if err := r.client.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		leftover = true
+		if object.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := r.client.Delete(ctx, object, &client.DeleteOptions{PropagationPolicy: &deletePolicy}); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	}
+	return leftover, nil
+}
+
+// jobConditionTrue reports whether the Job has the condition
+func jobConditionTrue(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// equalExitCode reports whether both exit codes are unset or the same
+func equalExitCode(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newMigrationScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+	_ = batchv1.AddToScheme(s)
+	return s
+}
+
+// finishCopy binds the staging target PVC to a provisioned PV and completes the copy Job with the exit code
+func finishCopy(t *testing.T, c client.Client, name string, conditionType batchv1.JobConditionType, exitCode int32) {
+	ctx := context.Background()
+	var target corev1.PersistentVolumeClaim
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name + "-target"}, &target))
+	target.Spec.VolumeName = "fast-pv"
+	assert.NoError(t, c.Update(ctx, &target))
+	assert.NoError(t, c.Create(ctx, &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "fast-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName:              "fast",
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			ClaimRef:                      &corev1.ObjectReference{Namespace: target.Namespace, Name: target.Name},
+		},
+	}))
+
+	var job batchv1.Job
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name}, &job))
+	job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+	if conditionType == batchv1.JobComplete {
+		job.Status.Succeeded = 1
+	} else {
+		job.Status.Failed = migrationBackoffLimit + 1
+	}
+	assert.NoError(t, c.Status().Update(ctx, &job))
+	assert.NoError(t, c.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-abcde", Namespace: DefaultMigrationStagingNamespace, Labels: map[string]string{migrationLabel: name}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "copy",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, FinishedAt: metav1.NewTime(time.Now())}},
+			}},
+		},
+	}))
+}
+
+func TestPVCReclaimController_Reconcile_Migrate(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Restore = true
+	reclaim.Spec.MigrateTo = &v1alpha1.Migration{StorageClassName: "fast"}
+	objects := []client.Object{
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: "fast.csi.example.com"},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: DefaultMigrationStagingNamespace}},
+		newPreflightNamespace(),
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv, &batchv1.Job{}).
+		WithObjects(append(objects, reclaim, pv)...).Build()
+	controller := NewPVCReclaimController(fakeClient)
+	name := migrationName(reclaim)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	result, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, migrationPollInterval, result.RequeueAfter)
+
+	var source, target corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name + "-source"}, &source))
+	assert.Equal(t, "test-pv", source.Spec.VolumeName)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name + "-target"}, &target))
+	assert.Equal(t, "fast", *target.Spec.StorageClassName)
+	assert.Equal(t, resource.MustParse("10Gi"), target.Spec.Resources.Requests[corev1.ResourceStorage])
+
+	var job batchv1.Job
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name}, &job))
+	assert.Equal(t, DefaultMigrationImage, job.Spec.Template.Spec.Containers[0].Image)
+	assert.True(t, job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, source.Name, updatedPV.Spec.ClaimRef.Name)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.MigrationCopying, updatedReclaim.Status.Migration.Phase)
+	assert.Equal(t, v1alpha1.RecoveryInProgress, updatedReclaim.Status.RecoverStatus)
+
+	finishCopy(t, fakeClient, name, batchv1.JobComplete, 0)
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.MigrationHandingOver, updatedReclaim.Status.Migration.Phase)
+	assert.Equal(t, "fast-pv", updatedReclaim.Status.Migration.TargetPersistentVolumeName)
+	assert.Equal(t, int32(0), *updatedReclaim.Status.Migration.ExitCode)
+
+	// the staging target PVC is deleted while the new PV is retained
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	var targetPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "fast-pv"}, &targetPV))
+	assert.Equal(t, corev1.PersistentVolumeReclaimRetain, targetPV.Spec.PersistentVolumeReclaimPolicy)
+	err = fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name + "-target"}, &target)
+	assert.True(t, errors.IsNotFound(err))
+
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	var restored corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &restored))
+	assert.Equal(t, "fast-pv", restored.Spec.VolumeName)
+	assert.Equal(t, "fast", *restored.Spec.StorageClassName)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "fast-pv"}, &targetPV))
+	assert.Equal(t, restored.Name, targetPV.Spec.ClaimRef.Name)
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, targetPV.Spec.PersistentVolumeReclaimPolicy)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "default", updatedPV.Spec.ClaimRef.Namespace)
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+	err = fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name}, &job)
+	assert.True(t, errors.IsNotFound(err))
+	err = fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_MigrateFailed(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Restore = true
+	reclaim.Spec.MigrateTo = &v1alpha1.Migration{StorageClassName: "fast"}
+	objects := []client.Object{
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: "fast.csi.example.com"},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: DefaultMigrationStagingNamespace}},
+		newPreflightNamespace(),
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv, &batchv1.Job{}).
+		WithObjects(append(objects, reclaim, pv)...).Build()
+	controller := NewPVCReclaimController(fakeClient)
+	name := migrationName(reclaim)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	finishCopy(t, fakeClient, name, batchv1.JobFailed, 23)
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Equal(t, v1alpha1.MigrationFailed, updatedReclaim.Status.Migration.Phase)
+	assert.Equal(t, int32(23), *updatedReclaim.Status.Migration.ExitCode)
+	assert.Equal(t, migrationBackoffLimit+1, updatedReclaim.Status.Migration.Attempts)
+	assert.Contains(t, updatedReclaim.Status.Reason, "last exit code 23")
+
+	// the staging PVCs are gone while the Job is kept for its logs
+	var source corev1.PersistentVolumeClaim
+	err = fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name + "-source"}, &source)
+	assert.True(t, errors.IsNotFound(err))
+	var job batchv1.Job
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: DefaultMigrationStagingNamespace, Name: name}, &job))
+
+	// the released PV is reserved for the PVCReclaim again
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+}
+
+func TestPVCReclaimController_Reconcile_MigrateRefused(t *testing.T) {
+	ctx := context.Background()
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Restore = true
+	reclaim.Spec.MigrateTo = &v1alpha1.Migration{StorageClassName: "fast"}
+	reclaim.Spec.PersistentVolumeClaimSpec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	fakeClient := fake.NewClientBuilder().WithScheme(newMigrationScheme()).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Contains(t, updatedReclaim.Status.Reason, "StorageClass fast does not exist")
+	assert.Contains(t, updatedReclaim.Status.Reason, "staging namespace pvc-reclaim-system does not exist")
+	assert.Contains(t, updatedReclaim.Status.Reason, "Namespace: namespace default does not exist")
+	assert.Contains(t, updatedReclaim.Status.Reason, "Compatibility: PV test-pv does not support access mode ReadWriteMany")
+	assert.Len(t, updatedReclaim.Status.PreflightChecks, 3)
+	assert.Nil(t, updatedReclaim.Status.Migration)
+}
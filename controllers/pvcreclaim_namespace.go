@@ -0,0 +1,107 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// reclaimNamespaceLabel is set on a ClusterPVCReclaim to the namespace of the PVCReclaim it keeps
+const reclaimNamespaceLabel = "pvc-reclaim.yibozhuang.me/namespace"
+
+//+kubebuilder:rbac:groups=``,resources=namespaces,verbs=get;list;watch;create
+
+// clusterPVCReclaimName is the name of the ClusterPVCReclaim keeping the PVCReclaim
+func clusterPVCReclaimName(namespace, name string) string {
+	return fmt.Sprintf("%s.%s", namespace, name)
+}
+
+// terminatingNamespace returns the namespace of the PVCReclaim if it is being
+// deleted, or nil when it is not
+func (r *PVCReclaimController) terminatingNamespace(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) (*corev1.Namespace, error) {
+	var namespace corev1.Namespace
+	if err := r.client.Get(ctx, types.NamespacedName{Name: pvcReclaim.Namespace}, &namespace); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if namespace.DeletionTimestamp == nil {
+		return nil, nil
+	}
+	return &namespace, nil
+}
+
+// storeClusterPVCReclaim keeps a copy of the PVCReclaim, together with the
+// labels of its terminating namespace, in a cluster-scoped ClusterPVCReclaim and
+// then lets the PVCReclaim go so it does not hold up the namespace deletion. A
+// legal hold moves along with it, the PV keeps its legal hold finalizer and
+// the ClusterPVCReclaim gets one.
+func (r *PVCReclaimController) storeClusterPVCReclaim(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, namespace *corev1.Namespace) error {
+	logger := log.FromContext(ctx)
+
+	clusterPVCReclaim := v1alpha1.ClusterPVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterPVCReclaimName(pvcReclaim.Namespace, pvcReclaim.Name),
+			Labels: map[string]string{
+				reclaimNamespaceLabel: pvcReclaim.Namespace,
+				reclaimPVLabel:        pvcReclaim.Spec.PersistentVolumeRef.Name,
+			},
+		},
+		Spec: v1alpha1.ClusterPVCReclaimSpec{
+			Namespace: v1alpha1.ReclaimNamespace{
+				Name:        namespace.Name,
+				Labels:      namespace.Labels,
+				Annotations: namespace.Annotations,
+			},
+			PVCReclaim: v1alpha1.PVCReclaimTemplate{
+				Name:        pvcReclaim.Name,
+				Labels:      pvcReclaim.Labels,
+				Annotations: withoutReclaimMetadata(pvcReclaim.Annotations),
+				Spec:        *pvcReclaim.Spec.DeepCopy(),
+				ClaimUID:    pvcReclaim.Status.ClaimUID,
+				LegalHold:   pvcReclaim.Status.LegalHold.DeepCopy(),
+			},
+		},
+	}
+	clusterPVCReclaim.Spec.PVCReclaim.Spec.Restore = false
+	if legalHoldActive(pvcReclaim) {
+		// the ClusterPVCReclaim enforces the hold from now on
+		controllerutil.AddFinalizer(&clusterPVCReclaim, legalHoldFinalizer)
+	}
+
+	logger.Info("Namespace of PVCReclaim is terminating, storing it as ClusterPVCReclaim", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), "ClusterPVCReclaim", clusterPVCReclaim.Name)
+	if err := r.client.Create(ctx, &clusterPVCReclaim); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	removedProtection := controllerutil.RemoveFinalizer(pvcReclaim, protectionFinalizer)
+	removedLegalHold := controllerutil.RemoveFinalizer(pvcReclaim, legalHoldFinalizer)
+	if !removedProtection && !removedLegalHold {
+		return nil
+	}
+	return r.client.Patch(ctx, pvcReclaim, patch)
+}
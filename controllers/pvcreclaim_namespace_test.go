@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_NamespaceTerminating_StoresClusterPVCReclaim(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	now := metav1.Now()
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "default",
+			Labels:            map[string]string{"team": "storage"},
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"kubernetes"},
+		},
+	}
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.LegalHold = &v1alpha1.LegalHold{
+		Enabled:     true,
+		RequestedBy: "compliance@example.com",
+		Reason:      "case 42",
+	}
+	reclaim.Finalizers = []string{protectionFinalizer, legalHoldFinalizer}
+	reclaim.DeletionTimestamp = &now
+	reclaim.Status.LegalHold = &v1alpha1.LegalHoldStatus{Active: true, PlacedBy: "compliance@example.com"}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(namespace, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var clusterPVCReclaim v1alpha1.ClusterPVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "default.test-reclaim"}, &clusterPVCReclaim))
+	assert.Equal(t, "default", clusterPVCReclaim.Labels[reclaimNamespaceLabel])
+	assert.Equal(t, map[string]string{"team": "storage"}, clusterPVCReclaim.Spec.Namespace.Labels)
+	assert.Equal(t, "test-pv", clusterPVCReclaim.Spec.PVCReclaim.Spec.PersistentVolumeRef.Name)
+	assert.Equal(t, types.UID("old-pvc-uid"), clusterPVCReclaim.Spec.PVCReclaim.ClaimUID)
+	assert.True(t, clusterPVCReclaim.Spec.PVCReclaim.Spec.LegalHold.Enabled)
+	assert.Equal(t, []string{legalHoldFinalizer}, clusterPVCReclaim.Finalizers)
+
+	// the PVCReclaim no longer holds up the namespace deletion
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &v1alpha1.PVCReclaim{})
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattributesclasses,verbs=get;list;watch
+
+// applyRestoreOverrides changes the restored PVC as the overrides ask
+func applyRestoreOverrides(pvc *corev1.PersistentVolumeClaim, overrides *v1alpha1.RestoreOverrides) {
+	if overrides == nil {
+		return
+	}
+	if overrides.StorageRequest != nil {
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = overrides.StorageRequest.DeepCopy()
+	}
+	if len(overrides.Labels) > 0 && pvc.Labels == nil {
+		pvc.Labels = make(map[string]string, len(overrides.Labels))
+	}
+	for key, value := range overrides.Labels {
+		pvc.Labels[key] = value
+	}
+	if len(overrides.Annotations) > 0 && pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string, len(overrides.Annotations))
+	}
+	for key, value := range overrides.Annotations {
+		pvc.Annotations[key] = value
+	}
+	if overrides.VolumeAttributesClassName != nil {
+		pvc.Spec.VolumeAttributesClassName = overrides.VolumeAttributesClassName
+	}
+}
+
+// checkRestoreOverrides verifies the overrides of the PVCReclaim are valid
+// metadata and can be applied to the PV, a storage request above its capacity
+// needs a StorageClass allowing expansion and a VolumeAttributesClass has to
+// belong to its CSI driver
+func checkRestoreOverrides(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, error) {
+	overrides := pvcReclaim.Spec.RestoreOverrides
+	if overrides == nil {
+		return "", nil
+	}
+
+	path := field.NewPath("spec", "restoreOverrides")
+	errs := metav1validation.ValidateLabels(overrides.Labels, path.Child("labels"))
+	errs = append(errs, apivalidation.ValidateAnnotations(overrides.Annotations, path.Child("annotations"))...)
+	if len(errs) > 0 {
+		return errs.ToAggregate().Error(), nil
+	}
+
+	if request := overrides.StorageRequest; request != nil {
+		if request.Sign() <= 0 {
+			return fmt.Sprintf("storage request override %s must be positive", request.String()), nil
+		}
+		capacity, hasCapacity := pv.Spec.Capacity[corev1.ResourceStorage]
+		if hasCapacity && request.Cmp(capacity) > 0 {
+			if message, err := checkVolumeExpansion(ctx, c, pvcReclaim, pv, request.String()); message != "" || err != nil {
+				return message, err
+			}
+		}
+	}
+
+	if name := overrides.VolumeAttributesClassName; name != nil && *name != "" {
+		if pv.Spec.CSI == nil {
+			return fmt.Sprintf("PV %s is not a CSI volume, VolumeAttributesClass %s cannot apply to it", pv.Name, *name), nil
+		}
+		var volumeAttributesClass storagev1beta1.VolumeAttributesClass
+		if err := c.Get(ctx, types.NamespacedName{Name: *name}, &volumeAttributesClass); err != nil {
+			if errors.IsNotFound(err) {
+				return fmt.Sprintf("VolumeAttributesClass %s does not exist", *name), nil
+			}
+			if meta.IsNoMatchError(err) {
+				return "VolumeAttributesClasses are not served by the cluster", nil
+			}
+			return "", err
+		}
+		if volumeAttributesClass.DriverName != pv.Spec.CSI.Driver {
+			return fmt.Sprintf("VolumeAttributesClass %s is for driver %s but PV %s is provisioned by %s", *name, volumeAttributesClass.DriverName, pv.Name, pv.Spec.CSI.Driver), nil
+		}
+	}
+	return "", nil
+}
+
+// checkVolumeExpansion verifies the StorageClass of the PVC allows the PV to be expanded to the request
+func checkVolumeExpansion(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, request string) (string, error) {
+	storageClassName := pvcReclaim.Spec.PersistentVolumeClaimSpec.StorageClassName
+	if storageClassName == nil || *storageClassName == "" {
+		return fmt.Sprintf("storage request override %s exceeds the capacity of PV %s and the PVC has no StorageClass to expand it", request, pv.Name), nil
+	}
+	var storageClass storagev1.StorageClass
+	if err := c.Get(ctx, types.NamespacedName{Name: *storageClassName}, &storageClass); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("storage request override %s exceeds the capacity of PV %s and StorageClass %s does not exist", request, pv.Name, *storageClassName), nil
+		}
+		return "", err
+	}
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		return fmt.Sprintf("storage request override %s exceeds the capacity of PV %s and StorageClass %s does not allow volume expansion", request, pv.Name, *storageClassName), nil
+	}
+	return "", nil
+}
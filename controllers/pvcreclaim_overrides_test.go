@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_Restore_Overrides(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+	_ = storagev1beta1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	reclaim.Labels["app"] = "db"
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	storageRequest := resource.MustParse("20Gi")
+	volumeAttributesClassName := "fast"
+	reclaim.Spec.RestoreOverrides = &v1alpha1.RestoreOverrides{
+		StorageRequest:            &storageRequest,
+		Labels:                    map[string]string{"tier": "gold"},
+		Annotations:               map[string]string{"restored-by": "oncall"},
+		VolumeAttributesClassName: &volumeAttributesClassName,
+	}
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+		AllowVolumeExpansion: &[]bool{true}[0],
+	}
+	volumeAttributesClass := &storagev1beta1.VolumeAttributesClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "fast"},
+		DriverName: "test.csi.yibozhuang.me",
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, &corev1.PersistentVolumeClaim{}).WithObjects(newPreflightNamespace(), storageClass, volumeAttributesClass, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var pvc corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &pvc))
+	request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "20Gi", request.String())
+	assert.Equal(t, "gold", pvc.Labels["tier"])
+	assert.Equal(t, "db", pvc.Labels["app"])
+	assert.Equal(t, "oncall", pvc.Annotations["restored-by"])
+	assert.Equal(t, &volumeAttributesClassName, pvc.Spec.VolumeAttributesClassName)
+	assert.Equal(t, "test-pv", pvc.Spec.VolumeName)
+}
+
+func TestCheckRestoreOverrides(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = storagev1.AddToScheme(s)
+	_ = storagev1beta1.AddToScheme(s)
+
+	storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	volumeAttributesClass := &storagev1beta1.VolumeAttributesClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "fast"},
+		DriverName: "other.csi.yibozhuang.me",
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(storageClass, volumeAttributesClass).Build()
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	message, err := checkRestoreOverrides(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Empty(t, message)
+
+	smaller := resource.MustParse("5Gi")
+	reclaim.Spec.RestoreOverrides = &v1alpha1.RestoreOverrides{StorageRequest: &smaller}
+	message, err = checkRestoreOverrides(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Empty(t, message)
+
+	larger := resource.MustParse("20Gi")
+	reclaim.Spec.RestoreOverrides = &v1alpha1.RestoreOverrides{StorageRequest: &larger}
+	message, err = checkRestoreOverrides(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "does not allow volume expansion")
+
+	reclaim.Spec.RestoreOverrides = &v1alpha1.RestoreOverrides{Labels: map[string]string{"bad key!": "x"}}
+	message, err = checkRestoreOverrides(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "spec.restoreOverrides.labels")
+
+	volumeAttributesClassName := "fast"
+	reclaim.Spec.RestoreOverrides = &v1alpha1.RestoreOverrides{VolumeAttributesClassName: &volumeAttributesClassName}
+	message, err = checkRestoreOverrides(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "not a CSI volume")
+
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	message, err = checkRestoreOverrides(context.Background(), fakeClient, reclaim, pv)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "is for driver other.csi.yibozhuang.me")
+}
+
+func TestPVCReclaimController_Reconcile_Restore_InvalidOverrides(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	larger := resource.MustParse("20Gi")
+	reclaim.Spec.RestoreOverrides = &v1alpha1.RestoreOverrides{StorageRequest: &larger}
+	storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(newPreflightNamespace(), storageClass, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Contains(t, updatedReclaim.Status.Reason, "RestoreOverrides: storage request override 20Gi exceeds the capacity")
+}
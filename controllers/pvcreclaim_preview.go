@@ -0,0 +1,249 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// DefaultPreviewImage is the image of the inspection pod of a preview
+	DefaultPreviewImage = "busybox:1.36"
+	// DefaultPreviewTimeout is how long a preview runs before it is torn down
+	DefaultPreviewTimeout = 30 * time.Minute
+	// previewLabel marks the scratch PVC and the inspection pod of a preview with the name of its PVCReclaim
+	previewLabel = "pvc-reclaim.yibozhuang.me/preview"
+	// previewMountPath is where the inspection pod mounts the PV
+	previewMountPath = "/data"
+	// previewPollInterval is how often the teardown of a preview is checked on
+	previewPollInterval = 5 * time.Second
+)
+
+//+kubebuilder:rbac:groups=``,resources=pods,verbs=get;list;watch;create;delete
+
+// transientClaim reports whether the PVC is a staging PVC of a migration, the
+// scratch PVC of a preview, scan or archive, or a PVC an archive is being
+// restored into, which come and go with them and are not reclaimed
+func transientClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	for _, label := range []string{migrationLabel, previewLabel, contentManifestLabel, archiveLabel} {
+		if _, found := pvc.Labels[label]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// previewName returns the name of the scratch PVC and the inspection pod of the PVCReclaim
+func previewName(pvcReclaim *v1alpha1.PVCReclaim) string {
+	return pvcReclaim.Name + "-preview"
+}
+
+// scratchClaim returns a PVC binding the PV so it can be mounted read-only.
+// It asks for ReadOnlyMany when the PV offers it and for the access modes of
+// the PV otherwise.
+func scratchClaim(name, namespace string, labels map[string]string, pv *corev1.PersistentVolume) *corev1.PersistentVolumeClaim {
+	accessModes := pv.Spec.AccessModes
+	if slices.Contains(accessModes, corev1.ReadOnlyMany) {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName:       pv.Name,
+			StorageClassName: &pv.Spec.StorageClassName,
+			AccessModes:      accessModes,
+			VolumeMode:       pv.Spec.VolumeMode,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: pv.Spec.Capacity[corev1.ResourceStorage],
+				},
+			},
+		},
+	}
+}
+
+// previewObjects returns the scratch PVC bound to the PV and the inspection pod mounting it read-only
+func (r *PVCReclaimController) previewObjects(pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (*corev1.PersistentVolumeClaim, *corev1.Pod) {
+	name := previewName(pvcReclaim)
+	labels := map[string]string{previewLabel: pvcReclaim.Name}
+	pvc := scratchClaim(name, pvcReclaim.Namespace, labels, pv)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pvcReclaim.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:       "inspect",
+				Image:      r.previewImage,
+				Command:    []string{"sleep", fmt.Sprintf("%d", int64(r.previewTimeout.Seconds()))},
+				WorkingDir: previewMountPath,
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "data", MountPath: previewMountPath, ReadOnly: true},
+				},
+			}},
+			Volumes: []corev1.Volume{
+				{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name, ReadOnly: true}}},
+			},
+		},
+	}
+	return pvc, pod
+}
+
+// preview starts a preview of the PV, or tears it down once it times out or preview is unset
+func (r *PVCReclaimController) preview(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	status := pvcReclaim.Status.Preview
+	if status == nil {
+		return r.startPreview(ctx, pvcReclaim, pv)
+	}
+	if remaining := time.Until(status.ExpiryTime.Time); pvcReclaim.Spec.Preview && remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	return r.endPreview(ctx, pvcReclaim, pv)
+}
+
+// startPreview binds the Released PV to the scratch PVC and launches the inspection pod
+func (r *PVCReclaimController) startPreview(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	pvc, pod := r.previewObjects(pvcReclaim, pv)
+
+	var blockers []string
+	if !restorable(pvcReclaim, pv) {
+		blockers = append(blockers, fmt.Sprintf("PV %s is not in Released phase", pv.Name))
+	}
+	for kind, object := range map[string]client.Object{"PVC": pvc.DeepCopy(), "Pod": pod.DeepCopy()} {
+		err := r.client.Get(ctx, client.ObjectKeyFromObject(object), object)
+		if err == nil {
+			blockers = append(blockers, fmt.Sprintf("%s %s/%s already exists", kind, object.GetNamespace(), object.GetName()))
+		} else if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+	slices.Sort(blockers)
+	if len(blockers) > 0 {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Preview = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.Message = fmt.Sprintf("Preview refused: %s", strings.Join(blockers, "; "))
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	// the scratch PVC and inspection pod are garbage collected with the PVCReclaim
+	for _, object := range []client.Object{pvc, pod} {
+		if err := controllerutil.SetControllerReference(pvcReclaim, object, r.client.Scheme()); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	logger.Info("Starting preview of PV", "pv", pv.Name, "pod", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := r.client.Create(ctx, pvc); err != nil {
+		return ctrl.Result{}, err
+	}
+	patch := client.MergeFrom(pv.DeepCopy())
+	bindPersistentVolume(pv, pvc, PersistentVolumeAnnotationPolicy{})
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Create(ctx, pod); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	now := metav1.Now()
+	pvcReclaim.Status.Preview = &v1alpha1.PreviewStatus{
+		ClaimName:  pvc.Name,
+		PodName:    pod.Name,
+		StartTime:  now,
+		ExpiryTime: metav1.NewTime(now.Add(r.previewTimeout)),
+	}
+	pvcReclaim.Status.Message = fmt.Sprintf("PV %s mounted read-only at %s in pod %s/%s until %s", pv.Name, previewMountPath, pod.Namespace, pod.Name, pvcReclaim.Status.Preview.ExpiryTime.UTC().Format(time.RFC3339))
+	if err := r.client.Status().Patch(ctx, pvcReclaim, statusPatch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.previewTimeout}, nil
+}
+
+// endPreview deletes the inspection pod and the scratch PVC, then returns the
+// PV to Released with the claimRef of the deleted PVC
+func (r *PVCReclaimController) endPreview(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	status := pvcReclaim.Status.Preview
+	objects := []client.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: status.PodName}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: pvcReclaim.Namespace, Name: status.ClaimName}},
+	}
+	leftover := false
+	for _, object := range objects {
+		if err := r.client.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return ctrl.Result{}, err
+		}
+		leftover = true
+		if object.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := r.client.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if leftover {
+		return ctrl.Result{RequeueAfter: previewPollInterval}, nil
+	}
+
+	log.FromContext(ctx).Info("Ending preview of PV", "pv", pv.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.ClaimRef = reservedClaimRef(pvcReclaim)
+	if err := r.client.Patch(ctx, pv, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if pvcReclaim.Spec.Preview {
+		specPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Preview = false
+		if err := r.client.Patch(ctx, pvcReclaim, specPatch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	statusPatch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Preview = nil
+	pvcReclaim.Status.Message = fmt.Sprintf("Preview of PV %s ended", pv.Name)
+	return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, statusPatch)
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_Preview(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Preview = true
+	pv.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadOnlyMany}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient, WithPreviewImage("alpine:3"), WithPreviewTimeout(time.Hour))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	result, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, result.RequeueAfter)
+
+	scratchName := types.NamespacedName{Name: "test-reclaim-preview", Namespace: "default"}
+	var scratch corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(ctx, scratchName, &scratch))
+	assert.Equal(t, []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}, scratch.Spec.AccessModes)
+	assert.Equal(t, "test-pv", scratch.Spec.VolumeName)
+	var pod corev1.Pod
+	assert.NoError(t, fakeClient.Get(ctx, scratchName, &pod))
+	assert.Equal(t, "alpine:3", pod.Spec.Containers[0].Image)
+	assert.True(t, pod.Spec.Containers[0].VolumeMounts[0].ReadOnly)
+	assert.True(t, pod.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+	assert.Equal(t, "test-reclaim", pod.OwnerReferences[0].Name)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, scratch.Name, updatedPV.Spec.ClaimRef.Name)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, pod.Name, updatedReclaim.Status.Preview.PodName)
+
+	// the preview is torn down once it times out
+	updatedReclaim.Status.Preview.ExpiryTime = metav1.NewTime(time.Now().Add(-time.Minute))
+	assert.NoError(t, fakeClient.Status().Update(ctx, &updatedReclaim))
+	result, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, previewPollInterval, result.RequeueAfter)
+	err = fakeClient.Get(ctx, scratchName, &pod)
+	assert.True(t, errors.IsNotFound(err))
+	err = fakeClient.Get(ctx, scratchName, &scratch)
+	assert.True(t, errors.IsNotFound(err))
+
+	_, err = controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+	assert.Equal(t, types.UID("old-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Preview)
+	assert.Nil(t, updatedReclaim.Status.Preview)
+}
+
+func TestPVCReclaimController_Reconcile_PreviewRefused(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Preview = true
+	pv.Status.Phase = corev1.VolumeBound
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(ctx, req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(ctx, req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Preview)
+	assert.Nil(t, updatedReclaim.Status.Preview)
+	assert.Contains(t, updatedReclaim.Status.Message, "PV test-pv is not in Released phase")
+	var pod corev1.Pod
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: "test-reclaim-preview", Namespace: "default"}, &pod)
+	assert.True(t, errors.IsNotFound(err))
+}
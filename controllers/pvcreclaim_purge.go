@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	provisionerDeletionSecretNameAnnotation      = "volume.kubernetes.io/provisioner-deletion-secret-name"
+	provisionerDeletionSecretNamespaceAnnotation = "volume.kubernetes.io/provisioner-deletion-secret-namespace"
+)
+
+//+kubebuilder:rbac:groups=``,resources=secrets,verbs=get
+
+// purge deletes the released PV together with its backing volume. When a CSI
+// controller endpoint is configured for the driver of the volume, DeleteVolume
+// is called directly and the PV object removed, otherwise the reclaim policy of
+// the PV is switched to Delete and the volume is left to its provisioner. The
+// PVCReclaim itself is deleted by Reconcile once the PV is gone, unless the
+// contents of the PV were archived first.
+func (r *PVCReclaimController) purge(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if pv.Status.Phase == corev1.VolumeBound {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Purge = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("PV %s is Bound and cannot be purged", pv.Name)
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	if pv.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	if legalHoldActive(pvcReclaim) {
+		reason := fmt.Sprintf("Purge of PV %s is suspended by legal hold placed by %s", pv.Name, pvcReclaim.Status.LegalHold.PlacedBy)
+		if pvcReclaim.Status.Reason == reason {
+			return ctrl.Result{}, nil
+		}
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.Reason = reason
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	if pvcReclaim.Spec.Archive != nil && !archived(pvcReclaim) {
+		return r.archiveBeforePurge(ctx, pvcReclaim, pv)
+	}
+
+	if pvcReclaim.Status.RecoverStatus != v1alpha1.PurgeInProgress {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.PurgeInProgress
+		pvcReclaim.Status.Reason = ""
+		pvcReclaim.Status.Message = fmt.Sprintf("Purging PV %s and its backing volume", pv.Name)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if r.csiControllerEndpoint != "" && pv.Spec.CSI != nil {
+		secrets, err := r.deletionSecrets(ctx, pv)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		err = deleteCSIVolume(ctx, r.csiControllerEndpoint, pv.Spec.CSI.Driver, pv.Spec.CSI.VolumeHandle, secrets)
+		var mismatch *errCSIDriverMismatch
+		switch {
+		case err == nil:
+			logger.Info("Deleted backing volume, deleting PV", "pv", pv.Name, "volumeHandle", pv.Spec.CSI.VolumeHandle)
+			return ctrl.Result{}, client.IgnoreNotFound(r.client.Delete(ctx, pv))
+		case errors.As(err, &mismatch):
+			logger.Info("CSI controller endpoint does not serve the driver of the PV, falling back to reclaim policy", "pv", pv.Name, "reason", err.Error())
+		default:
+			patch := client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Status.Reason = fmt.Sprintf("Failed to delete volume %s of PV %s, error: %v", pv.Spec.CSI.VolumeHandle, pv.Name, err)
+			if innerErr := r.client.Status().Patch(ctx, pvcReclaim, patch); innerErr != nil {
+				return ctrl.Result{}, innerErr
+			}
+			return ctrl.Result{}, err
+		}
+	}
+
+	if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimDelete {
+		return ctrl.Result{}, nil
+	}
+	logger.Info("Switching PV reclaim policy to Delete", "pv", pv.Name)
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	return ctrl.Result{}, r.client.Patch(ctx, pv, patch)
+}
+
+// deletionSecrets returns the CSI deletion secrets recorded on the PV by the external-provisioner, if any
+func (r *PVCReclaimController) deletionSecrets(ctx context.Context, pv *corev1.PersistentVolume) (map[string]string, error) {
+	name := pv.Annotations[provisionerDeletionSecretNameAnnotation]
+	namespace := pv.Annotations[provisionerDeletionSecretNamespaceAnnotation]
+	if name == "" || namespace == "" {
+		return nil, nil
+	}
+
+	var secret corev1.Secret
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string, len(secret.Data))
+	for key, val := range secret.Data {
+		secrets[key] = string(val)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeCSIController is a CSI identity and controller server recording DeleteVolume calls
+type fakeCSIController struct {
+	csi.UnimplementedIdentityServer
+	csi.UnimplementedControllerServer
+
+	driver string
+
+	mu      sync.Mutex
+	deleted map[string]map[string]string
+}
+
+func (f *fakeCSIController) GetPluginInfo(context.Context, *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
+	return &csi.GetPluginInfoResponse{Name: f.driver, VendorVersion: "test"}, nil
+}
+
+func (f *fakeCSIController) DeleteVolume(_ context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted[req.GetVolumeId()] = req.GetSecrets()
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+// deletedVolumes returns a copy of the DeleteVolume calls recorded so far
+func (f *fakeCSIController) deletedVolumes() map[string]map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deleted := make(map[string]map[string]string, len(f.deleted))
+	for volumeID, secrets := range f.deleted {
+		deleted[volumeID] = secrets
+	}
+	return deleted
+}
+
+func startFakeCSIController(t *testing.T, driver string) (*fakeCSIController, string) {
+	t.Helper()
+
+	endpoint := filepath.Join(t.TempDir(), "csi.sock")
+	listener, err := net.Listen("unix", endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakeCSI := &fakeCSIController{driver: driver, deleted: map[string]map[string]string{}}
+	server := grpc.NewServer()
+	csi.RegisterIdentityServer(server, fakeCSI)
+	csi.RegisterControllerServer(server, fakeCSI)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return fakeCSI, endpoint
+}
+
+func TestPVCReclaimController_Reconcile_Purge_CSIDeleteVolume(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	fakeCSI, endpoint := startFakeCSIController(t, "test.csi.yibozhuang.me")
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Purge = true
+	pv.Annotations = map[string]string{
+		provisionerDeletionSecretNameAnnotation:      "csi-secret",
+		provisionerDeletionSecretNamespaceAnnotation: "kube-system",
+	}
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:       "test.csi.yibozhuang.me",
+		VolumeHandle: "vol-1234",
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "csi-secret",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{"token": []byte("s3cr3t")},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv, secret).Build()
+	controller := NewPVCReclaimController(fakeClient, WithCSIControllerEndpoint(endpoint))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"token": "s3cr3t"}, fakeCSI.deletedVolumes()["vol-1234"])
+
+	var deletedPV corev1.PersistentVolume
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &deletedPV)
+	assert.True(t, errors.IsNotFound(err))
+
+	// the next reconcile finalizes the PVCReclaim now that the PV is gone
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	var deletedReclaim v1alpha1.PVCReclaim
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &deletedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_Purge_DriverMismatch(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	fakeCSI, endpoint := startFakeCSIController(t, "other.csi.yibozhuang.me")
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Purge = true
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:       "test.csi.yibozhuang.me",
+		VolumeHandle: "vol-1234",
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient, WithCSIControllerEndpoint(endpoint))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Empty(t, fakeCSI.deletedVolumes())
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+}
+
+func TestPVCReclaimController_Reconcile_Purge_SwitchesReclaimPolicy(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Purge = true
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:       "test.csi.yibozhuang.me",
+		VolumeHandle: "vol-1234",
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, corev1.PersistentVolumeReclaimDelete, updatedPV.Spec.PersistentVolumeReclaimPolicy)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.PurgeInProgress, updatedReclaim.Status.RecoverStatus)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// persistentVolumeDetails describes the PV for the PVCReclaim status, keeping
+// the release time already recorded while the PV stays Released
+func persistentVolumeDetails(pv *corev1.PersistentVolume, previous *v1alpha1.PersistentVolumeDetails) *v1alpha1.PersistentVolumeDetails {
+	details := &v1alpha1.PersistentVolumeDetails{
+		Phase:             pv.Status.Phase,
+		ReclaimPolicy:     pv.Spec.PersistentVolumeReclaimPolicy,
+		StorageClassName:  pv.Spec.StorageClassName,
+		DeletionTimestamp: pv.DeletionTimestamp,
+	}
+	if capacity, found := pv.Spec.Capacity[corev1.ResourceStorage]; found {
+		details.Capacity = &capacity
+	}
+	if pv.Status.Phase == corev1.VolumeReleased {
+		switch {
+		case previous != nil && previous.ReleasedAt != nil:
+			details.ReleasedAt = previous.ReleasedAt
+		case pv.Status.LastPhaseTransitionTime != nil:
+			details.ReleasedAt = pv.Status.LastPhaseTransitionTime
+		default:
+			now := metav1.Now()
+			details.ReleasedAt = &now
+		}
+	}
+	return details
+}
+
+// syncPersistentVolumeStatus mirrors the state of the PV into the PVCReclaim
+// status. pv is nil when the PV no longer exists.
+func (r *PVCReclaimController) syncPersistentVolumeStatus(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) error {
+	var details *v1alpha1.PersistentVolumeDetails
+	if pv != nil {
+		details = persistentVolumeDetails(pv, pvcReclaim.Status.PersistentVolume)
+	}
+	if equality.Semantic.DeepEqual(details, pvcReclaim.Status.PersistentVolume) {
+		return nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.PersistentVolume = details
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
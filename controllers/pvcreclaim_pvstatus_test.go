@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_MirrorsPersistentVolumeDetails(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	releasedAt := metav1.NewTime(metav1.Now().Add(-time.Hour))
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}
+	pv.Spec.StorageClassName = "standard"
+	pv.Status.LastPhaseTransitionTime = &releasedAt
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	details := updatedReclaim.Status.PersistentVolume
+	if assert.NotNil(t, details) {
+		assert.Equal(t, corev1.VolumeReleased, details.Phase)
+		assert.Equal(t, corev1.PersistentVolumeReclaimRetain, details.ReclaimPolicy)
+		assert.Equal(t, "standard", details.StorageClassName)
+		assert.True(t, resource.MustParse("10Gi").Equal(*details.Capacity))
+		if assert.NotNil(t, details.ReleasedAt) {
+			assert.Equal(t, releasedAt.Unix(), details.ReleasedAt.Unix())
+		}
+		assert.Nil(t, details.DeletionTimestamp)
+	}
+}
+
+func TestPersistentVolumeDetails_KeepsReleasedAt(t *testing.T) {
+	releasedAt := metav1.NewTime(metav1.Now().Add(-time.Hour))
+	pv := &corev1.PersistentVolume{
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased},
+	}
+
+	details := persistentVolumeDetails(pv, &v1alpha1.PersistentVolumeDetails{ReleasedAt: &releasedAt})
+	assert.Equal(t, &releasedAt, details.ReleasedAt)
+
+	pv.Status.Phase = corev1.VolumeBound
+	details = persistentVolumeDetails(pv, details)
+	assert.Nil(t, details.ReleasedAt)
+}
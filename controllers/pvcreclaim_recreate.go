@@ -0,0 +1,222 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const recreatedPVAnnotation = "pvc-reclaim.yibozhuang.me/recreated"
+
+// sanitizedPersistentVolumeSpec returns a copy of the PV spec without its
+// binding, suitable for re-creating the PV object statically
+func sanitizedPersistentVolumeSpec(pv *corev1.PersistentVolume) *corev1.PersistentVolumeSpec {
+	spec := pv.Spec.DeepCopy()
+	spec.ClaimRef = nil
+	return spec
+}
+
+// canRecreatePersistentVolume reports whether the backing volume outlives the
+// PV object and enough of the PV spec was recorded to bring the object back
+func canRecreatePersistentVolume(pvcReclaim *v1alpha1.PVCReclaim) bool {
+	spec := pvcReclaim.Spec.PersistentVolumeSpec
+	return spec != nil && spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain
+}
+
+// restorable reports whether the PV can be bound to a re-created PVC, either
+// because it was released by the deleted PVC or because it is reserved for the
+// PVCReclaim without being bound yet, as is the case for a re-created PV
+func restorable(pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) bool {
+	switch pv.Status.Phase {
+	case corev1.VolumeReleased:
+		return true
+	case corev1.VolumeBound, corev1.VolumeFailed:
+		return false
+	}
+	return pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.Namespace == pvcReclaim.Namespace && pv.Spec.ClaimRef.Name == pvcReclaim.Name
+}
+
+// recordPersistentVolumeSpec stores the sanitized PV spec on the PVCReclaim and
+// keeps it up to date while the PV exists, so a reclaim policy changed after
+// the PVC was deleted decides whether the PV can be re-created. It is only
+// called once the PV was verified not to be a replacement.
+func (r *PVCReclaimController) recordPersistentVolumeSpec(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) error {
+	spec := sanitizedPersistentVolumeSpec(pv)
+	if equality.Semantic.DeepEqual(pvcReclaim.Spec.PersistentVolumeSpec, spec) {
+		return nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.PersistentVolumeSpec = spec
+	return r.client.Patch(ctx, pvcReclaim, patch)
+}
+
+// handleMissingPersistentVolume deals with a PV object which no longer exists.
+// When the backing volume was retained rather than purged and the PV spec was
+// recorded, the PVCReclaim is kept and a restore re-creates the PV object,
+// either for the PVC that will be re-created or for an existing PVC left in the
+// Lost phase. A PVCReclaim with a snapshot of the volume is kept as well and
+// a restore provisions a new PVC from the snapshot. So is a PVCReclaim whose
+// volume was archived, even if it was purged, and a restore extracts the
+// archive into a new PVC. Otherwise there is nothing left to recover and the PVCReclaim is deleted.
+// pvc is nil when no PVC with the name of the PVCReclaim exists.
+func (r *PVCReclaimController) handleMissingPersistentVolume(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pvc *corev1.PersistentVolumeClaim) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	pvName := pvcReclaim.Spec.PersistentVolumeRef.Name
+
+	if pvcReclaim.Spec.Purge && archived(pvcReclaim) {
+		if err := r.keepArchive(ctx, pvcReclaim); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !pvcReclaim.Spec.Purge && !canRecreatePersistentVolume(pvcReclaim) {
+		// the volume is gone along with the PV but a snapshot or an archive of it may not be
+		snapshot, err := r.findSnapshot(ctx, pvcReclaim)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if snapshot != nil {
+			return r.restoreFromSnapshot(ctx, pvcReclaim, snapshot, pvc)
+		}
+		if archived(pvcReclaim) {
+			return r.restoreFromArchive(ctx, pvcReclaim, pvc)
+		}
+	}
+
+	if pvcReclaim.Spec.Purge || !canRecreatePersistentVolume(pvcReclaim) {
+		logger.Info("PV is not found, deleting PVCReclaim", "pv", pvName, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		return ctrl.Result{}, r.deletePVCReclaim(ctx, pvcReclaim)
+	}
+
+	if !pvcReclaim.Spec.Restore {
+		return ctrl.Result{}, r.markPersistentVolumeMissing(ctx, pvcReclaim, fmt.Sprintf("PV %s was deleted but its backing volume is retained, set restore to re-create it", pvName))
+	}
+
+	claimRef := &corev1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  pvcReclaim.Namespace,
+		Name:       pvcReclaim.Name,
+	}
+	lost := pvc != nil && pvc.Status.Phase == corev1.ClaimLost && pvc.Spec.VolumeName == pvName
+	if lost {
+		claimRef.UID = pvc.UID
+	}
+
+	pv := corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pvName,
+			Annotations: map[string]string{
+				recreatedPVAnnotation: "true",
+			},
+		},
+		Spec: *pvcReclaim.Spec.PersistentVolumeSpec.DeepCopy(),
+	}
+	pv.Spec.ClaimRef = claimRef
+
+	if pvcReclaim.Spec.DryRun {
+		var blockers []string
+		if pvc != nil && !lost {
+			blockers = append(blockers, fmt.Sprintf("PVC %s/%s already exists and is not Lost, PV %s cannot be re-created for it", pvc.Namespace, pvc.Name, pvName))
+		}
+		var lostPVC *corev1.PersistentVolumeClaim
+		if lost {
+			lostPVC = pvc
+		}
+		return ctrl.Result{}, r.planRestore(ctx, pvcReclaim, &pv, lostPVC, true, blockers)
+	}
+
+	if pvc != nil && !lost {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("PVC %s/%s already exists and is not Lost, PV %s cannot be re-created for it", pvc.Namespace, pvc.Name, pvName)
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	logger.Info("Re-creating deleted PV from recorded spec", "pv", pvName, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := r.client.Create(ctx, &pv); err != nil && !errors.IsAlreadyExists(err) {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("Failed to re-create PV %s, error: %v", pvName, err)
+		if innerErr := r.client.Status().Patch(ctx, pvcReclaim, patch); innerErr != nil {
+			return ctrl.Result{}, innerErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	// the re-created PV has a new UID
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.PersistentVolumeRef.UID = pv.UID
+	if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	meta.RemoveStatusCondition(&pvcReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing)
+	if !lost {
+		// the restore carries on with the re-created PV on the next reconcile
+		pvcReclaim.Status.Message = fmt.Sprintf("Re-created PV %s from recorded spec", pvName)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoverySuccess
+	pvcReclaim.Status.Reason = ""
+	pvcReclaim.Status.Message = fmt.Sprintf("Re-created PV %s for Lost PVC %s/%s", pvName, pvc.Namespace, pvc.Name)
+	if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.Restore = false
+	return ctrl.Result{}, r.client.Patch(ctx, pvcReclaim, patch)
+}
+
+// markPersistentVolumeMissing sets the PersistentVolumeMissing condition on the PVCReclaim kept after its PV was deleted
+func (r *PVCReclaimController) markPersistentVolumeMissing(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, message string) error {
+	if meta.IsStatusConditionTrue(pvcReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing) {
+		return nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Message = message
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionPersistentVolumeMissing,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             "PersistentVolumeDeleted",
+		Message:            message,
+	})
+	return r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_RecordsPersistentVolumeSpec(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	if assert.NotNil(t, updatedReclaim.Spec.PersistentVolumeSpec) {
+		assert.Nil(t, updatedReclaim.Spec.PersistentVolumeSpec.ClaimRef)
+		assert.Equal(t, "vol-1234", updatedReclaim.Spec.PersistentVolumeSpec.CSI.VolumeHandle)
+	}
+}
+
+func TestPVCReclaimController_Reconcile_RefreshesPersistentVolumeSpec(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, canRecreatePersistentVolume(&updatedReclaim))
+
+	// the admin retains the volume after the PVC was deleted
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	updatedPV.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+	assert.NoError(t, fakeClient.Update(context.Background(), &updatedPV))
+
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, corev1.PersistentVolumeReclaimRetain, updatedReclaim.Spec.PersistentVolumeSpec.PersistentVolumeReclaimPolicy)
+	assert.True(t, canRecreatePersistentVolume(&updatedReclaim))
+}
+
+func TestPVCReclaimController_Reconcile_RetainedPVMissing_KeepsReclaim(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, recorded := newReleasedFixtures()
+	recorded.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:           "test.csi.yibozhuang.me",
+		VolumeHandle:     "vol-1234",
+		VolumeAttributes: map[string]string{"tier": "gold"},
+	}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(recorded)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim).WithObjects(reclaim).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing))
+}
+
+func TestPVCReclaimController_Reconcile_RetainedPVMissing_Restore(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	reclaim, recorded := newReleasedFixtures()
+	recorded.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:           "test.csi.yibozhuang.me",
+		VolumeHandle:     "vol-1234",
+		VolumeAttributes: map[string]string{"tier": "gold"},
+	}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(recorded)
+	reclaim.Spec.Restore = true
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, &corev1.PersistentVolume{}, &corev1.PersistentVolumeClaim{}).WithObjects(newPreflightNamespace(), reclaim).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	result, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.True(t, result.Requeue)
+
+	var pv corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &pv))
+	assert.Equal(t, "true", pv.Annotations[recreatedPVAnnotation])
+	assert.Equal(t, "vol-1234", pv.Spec.CSI.VolumeHandle)
+	assert.Equal(t, map[string]string{"tier": "gold"}, pv.Spec.CSI.VolumeAttributes)
+	if assert.NotNil(t, pv.Spec.ClaimRef) {
+		assert.Equal(t, "test-reclaim", pv.Spec.ClaimRef.Name)
+		assert.Empty(t, pv.Spec.ClaimRef.UID)
+	}
+
+	// the restore carries on and binds a re-created PVC to the re-created PV
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var pvc corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &pvc))
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &pv))
+	assert.Equal(t, pvc.UID, pv.Spec.ClaimRef.UID)
+}
+
+func TestPVCReclaimController_Reconcile_RetainedPVMissing_LostPVC(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, recorded := newReleasedFixtures()
+	recorded.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:           "test.csi.yibozhuang.me",
+		VolumeHandle:     "vol-1234",
+		VolumeAttributes: map[string]string{"tier": "gold"},
+	}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(recorded)
+	reclaim.Spec.Restore = true
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+			UID:       types.UID("lost-pvc-uid"),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: "test-pv",
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimLost,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pvc).WithObjects(reclaim, pvc).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var pv corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &pv))
+	if assert.NotNil(t, pv.Spec.ClaimRef) {
+		assert.Equal(t, types.UID("lost-pvc-uid"), pv.Spec.ClaimRef.UID)
+	}
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.RecoverySuccess, updatedReclaim.Status.RecoverStatus)
+	assert.False(t, updatedReclaim.Spec.Restore)
+}
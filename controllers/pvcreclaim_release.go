@@ -0,0 +1,116 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// releaseToPool clears the claimRef of the PV so it becomes Available for a new
+// PVC, then retires the PVCReclaim once another claim has bound the PV. Only the
+// claimRef of the original PVC is cleared, a PV claimed by any other PVC is taken
+// even before its phase turns Bound.
+func (r *PVCReclaimController) releaseToPool(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	claimRef := pv.Spec.ClaimRef
+	if claimRef != nil && (pv.Status.Phase == corev1.VolumeBound || !originalClaim(pvcReclaim, claimRef)) {
+		if pvcReclaim.Status.RecoverStatus != v1alpha1.ReleasedToPool {
+			// the PV is still claimed by a PVC, nothing can be released
+			patch := client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Spec.ReleaseToPool = nil
+			if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+				return ctrl.Result{}, err
+			}
+			patch = client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+			pvcReclaim.Status.Reason = fmt.Sprintf("PV %s is still claimed by PVC %s/%s", pv.Name, claimRef.Namespace, claimRef.Name)
+			return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+		}
+
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.BoundClaimRef = claimRef.DeepCopy()
+		pvcReclaim.Status.Message = fmt.Sprintf("PV %s released to pool was bound by PVC %s/%s", pv.Name, claimRef.Namespace, claimRef.Name)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Deleting PVCReclaim after released PV was bound by a new PVC", "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name), "pvc", fmt.Sprintf("%s/%s", claimRef.Namespace, claimRef.Name))
+		return ctrl.Result{}, r.deletePVCReclaim(ctx, pvcReclaim)
+	}
+
+	labels := pvcReclaim.Spec.ReleaseToPool.Labels
+	storageClassName := pvcReclaim.Spec.ReleaseToPool.StorageClassName
+	_, recorded := pv.Annotations[claimRecordAnnotation]
+	reclassed := storageClassName == "" || pv.Spec.StorageClassName == storageClassName
+	if claimRef != nil || recorded || !hasLabels(pv.Labels, labels) || !reclassed {
+		logger.Info("Releasing PV to pool", "pv", pv.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		patch := client.MergeFrom(pv.DeepCopy())
+		pv.Spec.ClaimRef = nil
+		// the PV no longer describes the original PVC
+		delete(pv.Annotations, claimRecordAnnotation)
+		if len(labels) > 0 && pv.Labels == nil {
+			pv.Labels = make(map[string]string, len(labels))
+		}
+		for labelKey, labelVal := range labels {
+			pv.Labels[labelKey] = labelVal
+		}
+		if storageClassName != "" {
+			// PVCs without a selector only bind to a PV of the class they request
+			pv.Spec.StorageClassName = storageClassName
+		}
+		if err := r.client.Patch(ctx, pv, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if pvcReclaim.Status.RecoverStatus == v1alpha1.ReleasedToPool {
+		return ctrl.Result{}, nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.RecoverStatus = v1alpha1.ReleasedToPool
+	pvcReclaim.Status.Reason = ""
+	pvcReclaim.Status.Message = fmt.Sprintf("PV %s released to pool, waiting for a PVC to bind to it", pv.Name)
+	return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// originalClaim reports whether the claimRef names the deleted PVC of the PVCReclaim,
+// comparing UIDs only when both of them are known
+func originalClaim(pvcReclaim *v1alpha1.PVCReclaim, claimRef *corev1.ObjectReference) bool {
+	if claimRef.Namespace != pvcReclaim.Namespace || claimRef.Name != pvcReclaim.Name {
+		return false
+	}
+	return claimRef.UID == "" || pvcReclaim.Status.ClaimUID == "" || claimRef.UID == pvcReclaim.Status.ClaimUID
+}
+
+// hasLabels reports whether all the wanted labels are set on the object labels
+func hasLabels(objectLabels, wanted map[string]string) bool {
+	for labelKey, labelVal := range wanted {
+		if val, found := objectLabels[labelKey]; !found || val != labelVal {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_ReleaseToPool_ClearsClaimRef(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+			ReleaseToPool: &v1alpha1.PVCReclaimRelease{
+				Labels: map[string]string{"app": "migrated"},
+			},
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "default",
+				Name:      "test-reclaim",
+				UID:       types.UID("old-pvc-uid"),
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeReleased,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Nil(t, updatedPV.Spec.ClaimRef)
+	assert.Equal(t, "migrated", updatedPV.Labels["app"])
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.ReleasedToPool, updatedReclaim.Status.RecoverStatus)
+}
+
+func TestPVCReclaimController_Reconcile_ReleaseToPool_BoundByNewClaim(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+			ReleaseToPool: &v1alpha1.PVCReclaimRelease{},
+		},
+		Status: v1alpha1.PVCReclaimStatus{
+			RecoverStatus: v1alpha1.ReleasedToPool,
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "other",
+				Name:      "new-claim",
+				UID:       types.UID("new-pvc-uid"),
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeBound,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPVCReclaimController_Reconcile_ReleaseToPool_StillBound(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+			ReleaseToPool: &v1alpha1.PVCReclaimRelease{},
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "default",
+				Name:      "test-reclaim",
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeBound,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Nil(t, updatedReclaim.Spec.ReleaseToPool)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+}
+
+func TestPVCReclaimController_Reconcile_ReleaseToPool_StorageClass(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+			ReleaseToPool: &v1alpha1.PVCReclaimRelease{
+				StorageClassName: "reclaim-pool",
+			},
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName: "standard",
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "default",
+				Name:      "test-reclaim",
+				UID:       types.UID("old-pvc-uid"),
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeReleased,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Nil(t, updatedPV.Spec.ClaimRef)
+	assert.Equal(t, "reclaim-pool", updatedPV.Spec.StorageClassName)
+}
+
+func TestPVCReclaimController_Reconcile_ReleaseToPool_ClaimedByOtherPVC(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolume",
+				APIVersion: "v1",
+				Name:       "test-pv",
+			},
+			ReleaseToPool: &v1alpha1.PVCReclaimRelease{},
+		},
+		Status: v1alpha1.PVCReclaimStatus{
+			ClaimUID: types.UID("old-pvc-uid"),
+		},
+	}
+	// another PVC pre-bound the PV, which has not turned Bound yet
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "other",
+				Name:      "new-claim",
+				UID:       types.UID("new-pvc-uid"),
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeAvailable,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	if assert.NotNil(t, updatedPV.Spec.ClaimRef) {
+		assert.Equal(t, "new-claim", updatedPV.Spec.ClaimRef.Name)
+		assert.Equal(t, types.UID("new-pvc-uid"), updatedPV.Spec.ClaimRef.UID)
+	}
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Nil(t, updatedReclaim.Spec.ReleaseToPool)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Equal(t, "PV test-pv is still claimed by PVC other/new-claim", updatedReclaim.Status.Reason)
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// findSnapshot returns the VolumeSnapshot a PVCReclaim can be restored from
+// once its PV is gone, nil if there is none. That is the snapshot recorded in
+// its status or else the newest ready snapshot taken of the volume of the PVC
+// it was created for, which is recorded in its status as it is found.
+func (r *PVCReclaimController) findSnapshot(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) (*snapshotv1.VolumeSnapshot, error) {
+	if reference := pvcReclaim.Status.Snapshot; reference != nil {
+		var snapshot snapshotv1.VolumeSnapshot
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: reference.Name}, &snapshot); err != nil {
+			if errors.IsNotFound(err) || snapshotsUnavailable(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return &snapshot, nil
+	}
+
+	var snapshots snapshotv1.VolumeSnapshotList
+	if err := r.client.List(ctx, &snapshots, client.InNamespace(pvcReclaim.Namespace)); err != nil {
+		if snapshotsUnavailable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var found *snapshotv1.VolumeSnapshot
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		source := snapshot.Spec.Source.PersistentVolumeClaimName
+		if source == nil || *source != pvcReclaim.Name || !snapshotReady(snapshot) {
+			continue
+		}
+		if found != nil && !found.Status.CreationTime.Before(snapshot.Status.CreationTime) {
+			continue
+		}
+		released, err := r.snapshotOfReleasedVolume(ctx, pvcReclaim, snapshot)
+		if err != nil {
+			return nil, err
+		}
+		if released {
+			found = snapshot
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+
+	log.FromContext(ctx).Info("Found VolumeSnapshot of the PVC", "snapshot", found.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Snapshot = &v1alpha1.SnapshotReference{
+		Name: found.Name,
+	}
+	if found.Status.BoundVolumeSnapshotContentName != nil {
+		pvcReclaim.Status.Snapshot.ContentName = *found.Status.BoundVolumeSnapshotContentName
+	}
+	if found.Spec.VolumeSnapshotClassName != nil {
+		pvcReclaim.Status.Snapshot.VolumeSnapshotClassName = *found.Spec.VolumeSnapshotClassName
+	}
+	if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+		return nil, err
+	}
+	return found, recordSnapshotStatus(ctx, r.client, pvcReclaim, found)
+}
+
+// snapshotOfReleasedVolume reports whether a VolumeSnapshot naming the PVC of
+// the PVCReclaim as its source was taken of the released volume rather than of
+// a later PVC which reused the name, whenever it was taken. When the CSI
+// volumeHandle of the PV was recorded, its VolumeSnapshotContent must be of
+// that volume. It was not recorded only if the PV was never seen.
+func (r *PVCReclaimController) snapshotOfReleasedVolume(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, snapshot *snapshotv1.VolumeSnapshot) (bool, error) {
+	spec := pvcReclaim.Spec.PersistentVolumeSpec
+	if spec == nil || spec.CSI == nil || spec.CSI.VolumeHandle == "" {
+		return true, nil
+	}
+	contentName := snapshot.Status.BoundVolumeSnapshotContentName
+	if contentName == nil {
+		return false, nil
+	}
+	var content snapshotv1.VolumeSnapshotContent
+	if err := r.client.Get(ctx, types.NamespacedName{Name: *contentName}, &content); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	volumeHandle := content.Spec.Source.VolumeHandle
+	return content.Spec.Driver == spec.CSI.Driver && volumeHandle != nil && *volumeHandle == spec.CSI.VolumeHandle, nil
+}
+
+// snapshotsUnavailable reports whether the error is due to VolumeSnapshots not
+// being served by the cluster or known to the client
+func snapshotsUnavailable(err error) bool {
+	return meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err)
+}
+
+// snapshotReady reports whether a PVC can be provisioned from the VolumeSnapshot
+func snapshotReady(snapshot *snapshotv1.VolumeSnapshot) bool {
+	status := snapshot.Status
+	return status != nil && status.ReadyToUse != nil && *status.ReadyToUse && status.CreationTime != nil
+}
+
+// snapshotRestoredPersistentVolumeClaim builds the PVC a restore provisions
+// from the VolumeSnapshot, which requests at least the restore size of the
+// snapshot and is not bound to the deleted PV
+func snapshotRestoredPersistentVolumeClaim(pvcReclaim *v1alpha1.PVCReclaim, sanitizer *Sanitizer, snapshot *snapshotv1.VolumeSnapshot) *corev1.PersistentVolumeClaim {
+	pvc := restoredPersistentVolumeClaim(pvcReclaim, sanitizer)
+	pvc.Spec.VolumeName = ""
+	apiGroup := snapshotv1.GroupName
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshot.Name,
+	}
+	pvc.Spec.DataSourceRef = &corev1.TypedObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshot.Name,
+	}
+	if snapshot.Status != nil && snapshot.Status.RestoreSize != nil {
+		request, hasRequest := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if !hasRequest || request.Cmp(*snapshot.Status.RestoreSize) < 0 {
+			if pvc.Spec.Resources.Requests == nil {
+				pvc.Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = snapshot.Status.RestoreSize.DeepCopy()
+		}
+	}
+	return pvc
+}
+
+// restoredFromSnapshot reports whether the PVC is provisioned from the VolumeSnapshot
+func restoredFromSnapshot(pvc *corev1.PersistentVolumeClaim, snapshot *snapshotv1.VolumeSnapshot) bool {
+	ref := pvc.Spec.DataSourceRef
+	return ref != nil && ref.APIGroup != nil && *ref.APIGroup == snapshotv1.GroupName && ref.Kind == "VolumeSnapshot" && ref.Name == snapshot.Name
+}
+
+// restoreFromSnapshot restores a PVCReclaim whose PV is gone by provisioning a
+// new PVC from the VolumeSnapshot with the recorded claim spec. The restore
+// completes, and the PVCReclaim is deleted, once the PVC is Bound. pvc is nil
+// when no PVC with the name of the PVCReclaim exists.
+func (r *PVCReclaimController) restoreFromSnapshot(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, snapshot *snapshotv1.VolumeSnapshot, pvc *corev1.PersistentVolumeClaim) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	pvName := pvcReclaim.Spec.PersistentVolumeRef.Name
+
+	if pvc != nil && restoredFromSnapshot(pvc, snapshot) {
+		if pvc.Status.Phase != corev1.ClaimBound {
+			return ctrl.Result{RequeueAfter: snapshotPollInterval}, nil
+		}
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoverySuccess
+		pvcReclaim.Status.Reason = ""
+		pvcReclaim.Status.Message = fmt.Sprintf("Successfully restored PVC %s/%s from VolumeSnapshot %s", pvc.Namespace, pvc.Name, snapshot.Name)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("Deleting PVCReclaim after successfully restoring PVC from snapshot", "snapshot", snapshot.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		return ctrl.Result{}, r.deletePVCReclaim(ctx, pvcReclaim)
+	}
+
+	if !pvcReclaim.Spec.Restore {
+		return ctrl.Result{}, r.markPersistentVolumeMissing(ctx, pvcReclaim, fmt.Sprintf("PV %s was deleted, set restore to provision a new PVC from VolumeSnapshot %s", pvName, snapshot.Name))
+	}
+
+	var blockers []string
+	if pvc != nil {
+		blockers = append(blockers, fmt.Sprintf("PVC %s/%s already exists, it cannot be restored from VolumeSnapshot %s", pvc.Namespace, pvc.Name, snapshot.Name))
+	}
+	if !snapshotReady(snapshot) {
+		blockers = append(blockers, fmt.Sprintf("VolumeSnapshot %s is not ready to use", snapshot.Name))
+	}
+	restored := snapshotRestoredPersistentVolumeClaim(pvcReclaim, r.sanitizer, snapshot)
+
+	if pvcReclaim.Spec.DryRun {
+		plan := &v1alpha1.RestorePlan{
+			Ready:    len(blockers) == 0,
+			Blockers: blockers,
+		}
+		manifest, err := renderManifest(restored, "PersistentVolumeClaim")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		plan.PersistentVolumeClaim = manifest
+		return ctrl.Result{}, r.recordRestorePlan(ctx, pvcReclaim, plan)
+	}
+
+	if len(blockers) > 0 {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = strings.Join(blockers, "; ")
+		return ctrl.Result{}, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	logger.Info("Restoring PVC from snapshot", "pv", pvName, "snapshot", snapshot.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+	if err := r.client.Create(ctx, restored); err != nil && !errors.IsAlreadyExists(err) {
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("Failed to restore PVC %s/%s from VolumeSnapshot %s, error: %v", restored.Namespace, restored.Name, snapshot.Name, err)
+		if innerErr := r.client.Status().Patch(ctx, pvcReclaim, patch); innerErr != nil {
+			return ctrl.Result{}, innerErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	meta.RemoveStatusCondition(&pvcReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing)
+	pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryInProgress
+	pvcReclaim.Status.Reason = ""
+	pvcReclaim.Status.Message = fmt.Sprintf("Provisioning PVC %s/%s from VolumeSnapshot %s", restored.Namespace, restored.Name, snapshot.Name)
+	if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	patch = client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Spec.Restore = false
+	if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: snapshotPollInterval}, nil
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newReadySnapshot(name string, created time.Time) *snapshotv1.VolumeSnapshot {
+	restoreSize := resource.MustParse("20Gi")
+	return &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Status: &snapshotv1.VolumeSnapshotStatus{
+			CreationTime: &metav1.Time{Time: created},
+			ReadyToUse:   &[]bool{true}[0],
+			RestoreSize:  &restoreSize,
+		},
+	}
+}
+
+func TestPVCReclaimController_Reconcile_RestoreFromSnapshot(t *testing.T) {
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(pv)
+	reclaim.Spec.Restore = true
+	reclaim.Status.Snapshot = &v1alpha1.SnapshotReference{Name: "pvc-reclaim-old-pvc-uid"}
+	snapshot := newReadySnapshot("pvc-reclaim-old-pvc-uid", time.Now())
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim, &corev1.PersistentVolumeClaim{}).WithObjects(reclaim, snapshot).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	result, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotPollInterval, result.RequeueAfter)
+
+	var pvc corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &pvc))
+	assert.Empty(t, pvc.Spec.VolumeName)
+	assert.Equal(t, "VolumeSnapshot", pvc.Spec.DataSourceRef.Kind)
+	assert.Equal(t, "pvc-reclaim-old-pvc-uid", pvc.Spec.DataSourceRef.Name)
+	request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "20Gi", request.String())
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryInProgress, updatedReclaim.Status.RecoverStatus)
+
+	// the restore completes once the provisioned PVC is bound
+	pvc.Status.Phase = corev1.ClaimBound
+	assert.NoError(t, fakeClient.Status().Update(context.Background(), &pvc))
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+// newBoundSnapshot returns a ready VolumeSnapshot of the PVC bound to a VolumeSnapshotContent of the volume
+func newBoundSnapshot(name, pvcName, volumeHandle string, created time.Time) (*snapshotv1.VolumeSnapshot, *snapshotv1.VolumeSnapshotContent) {
+	snapshot := newReadySnapshot(name, created)
+	snapshot.Spec.Source.PersistentVolumeClaimName = &pvcName
+	contentName := "snapcontent-" + name
+	snapshot.Status.BoundVolumeSnapshotContentName = &contentName
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: contentName,
+		},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			Driver: "test.csi.yibozhuang.me",
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				VolumeHandle: &volumeHandle,
+			},
+		},
+	}
+	return snapshot, content
+}
+
+func TestPVCReclaimController_Reconcile_DiscoversSnapshot(t *testing.T) {
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(pv)
+	// the PVCReclaim was created when the PVC was bound, long before its last snapshots
+	reclaim.CreationTimestamp = metav1.NewTime(time.Now().Add(-72 * time.Hour))
+	older, olderContent := newBoundSnapshot("nightly-1", "test-reclaim", "vol-1234", time.Now().Add(-48*time.Hour))
+	newer, newerContent := newBoundSnapshot("nightly-2", "test-reclaim", "vol-1234", time.Now().Add(-24*time.Hour))
+	// a later PVC reused the name of the deleted one
+	reused, reusedContent := newBoundSnapshot("nightly-3", "test-reclaim", "vol-5678", time.Now().Add(-12*time.Hour))
+	newest, newestContent := newBoundSnapshot("nightly-4", "test-reclaim", "vol-1234", time.Now().Add(-30*time.Minute))
+	other, otherContent := newBoundSnapshot("nightly-5", "other-pvc", "vol-1234", time.Now())
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim).WithObjects(reclaim,
+		older, olderContent, newer, newerContent, reused, reusedContent, newest, newestContent, other, otherContent).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, "nightly-4", updatedReclaim.Status.Snapshot.Name)
+	assert.Equal(t, "snapcontent-nightly-4", updatedReclaim.Status.Snapshot.ContentName)
+	assert.True(t, updatedReclaim.Status.Snapshot.ReadyToUse)
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing))
+	assert.Contains(t, updatedReclaim.Status.Message, "from VolumeSnapshot nightly-4")
+}
+
+func TestPVCReclaimController_Reconcile_RestoreFromSnapshot_Refused(t *testing.T) {
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(pv)
+	reclaim.Spec.Restore = true
+	reclaim.Status.Snapshot = &v1alpha1.SnapshotReference{Name: "pvc-reclaim-old-pvc-uid"}
+	snapshot := newReadySnapshot("pvc-reclaim-old-pvc-uid", time.Now())
+	snapshot.Status.ReadyToUse = &[]bool{false}[0]
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-reclaim",
+			Namespace: "default",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim).WithObjects(reclaim, snapshot, pvc).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.Contains(t, updatedReclaim.Status.Reason, "PVC default/test-reclaim already exists")
+	assert.Contains(t, updatedReclaim.Status.Reason, "is not ready to use")
+}
+
+func TestPVCReclaimController_Reconcile_RestoreFromSnapshot_DryRun(t *testing.T) {
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(pv)
+	reclaim.Spec.Restore = true
+	reclaim.Status.Snapshot = &v1alpha1.SnapshotReference{Name: "pvc-reclaim-old-pvc-uid"}
+	reclaim.Spec.DryRun = true
+	snapshot := newReadySnapshot("pvc-reclaim-old-pvc-uid", time.Now())
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim).WithObjects(reclaim, snapshot).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var pvc corev1.PersistentVolumeClaim
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &pvc)
+	assert.True(t, errors.IsNotFound(err))
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, updatedReclaim.Spec.Restore)
+	assert.True(t, updatedReclaim.Status.RestorePlan.Ready)
+	assert.Contains(t, updatedReclaim.Status.RestorePlan.PersistentVolumeClaim, "kind: VolumeSnapshot")
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// persistentVolumeReplaced compares the PV against the UID and CSI volumeHandle
+// recorded on the PVCReclaim and describes the mismatch if the PV is a
+// different volume created under the same name
+func persistentVolumeReplaced(pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (string, bool) {
+	ref := pvcReclaim.Spec.PersistentVolumeRef
+	if ref.UID != "" && ref.UID != pv.UID {
+		return fmt.Sprintf("PV %s has UID %s but PVCReclaim recorded UID %s", pv.Name, pv.UID, ref.UID), true
+	}
+
+	spec := pvcReclaim.Spec.PersistentVolumeSpec
+	if spec == nil || spec.CSI == nil {
+		return "", false
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.VolumeHandle != spec.CSI.VolumeHandle {
+		volumeHandle := "<none>"
+		if pv.Spec.CSI != nil {
+			volumeHandle = pv.Spec.CSI.VolumeHandle
+		}
+		return fmt.Sprintf("PV %s has volumeHandle %s but PVCReclaim recorded volumeHandle %s", pv.Name, volumeHandle, spec.CSI.VolumeHandle), true
+	}
+	return "", false
+}
+
+// verifyPersistentVolume checks the PV is still the one the PVCReclaim was
+// created for. A replaced PV raises the Stale condition and refuses a pending
+// restore, otherwise the UID of the PV is recorded if it was not yet and any
+// Stale condition is cleared. It reports whether the PV can be trusted.
+func (r *PVCReclaimController) verifyPersistentVolume(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	message, replaced := persistentVolumeReplaced(pvcReclaim, pv)
+	if !replaced {
+		if pvcReclaim.Spec.PersistentVolumeRef.UID == "" {
+			patch := client.MergeFrom(pvcReclaim.DeepCopy())
+			pvcReclaim.Spec.PersistentVolumeRef.UID = pv.UID
+			if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+				return false, err
+			}
+		}
+		if meta.FindStatusCondition(pvcReclaim.Status.Conditions, v1alpha1.ConditionStale) == nil {
+			return true, nil
+		}
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		meta.RemoveStatusCondition(&pvcReclaim.Status.Conditions, v1alpha1.ConditionStale)
+		return true, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	if pvcReclaim.Spec.Restore && !pvcReclaim.Spec.DryRun {
+		logger.Info("PV was replaced under the same name, refusing restore", "pv", pv.Name, "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return false, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = message
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return false, err
+		}
+	}
+
+	if meta.IsStatusConditionTrue(pvcReclaim.Status.Conditions, v1alpha1.ConditionStale) {
+		return false, nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionStale,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             "PersistentVolumeReplaced",
+		Message:            message,
+	})
+	return false, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
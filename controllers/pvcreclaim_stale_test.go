@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_RecordsPersistentVolumeUID(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	pv.UID = types.UID("pv-uid")
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, types.UID("pv-uid"), updatedReclaim.Spec.PersistentVolumeRef.UID)
+	assert.Nil(t, meta.FindStatusCondition(updatedReclaim.Status.Conditions, v1alpha1.ConditionStale))
+}
+
+func TestPVCReclaimController_Reconcile_ReplacedPV_UIDMismatch(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Restore = true
+	reclaim.Spec.PersistentVolumeRef.UID = types.UID("old-pv-uid")
+	pv.UID = types.UID("new-pv-uid")
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionStale))
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &corev1.PersistentVolumeClaim{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// the replaced PV is left alone
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.NotContains(t, updatedPV.Annotations, claimRecordAnnotation)
+}
+
+func TestPVCReclaimController_Reconcile_ReplacedPV_VolumeHandleMismatch(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, recorded := newReleasedFixtures()
+	recorded.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(recorded)
+	reclaim.Spec.Restore = true
+	// a PV of another volume was created under the same name
+	pv := &corev1.PersistentVolume{}
+	pv.Name = "test-pv"
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+		Driver:       "test.csi.yibozhuang.me",
+		VolumeHandle: "vol-5678",
+	}
+	pv.Status.Phase = corev1.VolumeReleased
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	condition := meta.FindStatusCondition(updatedReclaim.Status.Conditions, v1alpha1.ConditionStale)
+	if assert.NotNil(t, condition) {
+		assert.Contains(t, condition.Message, "vol-5678")
+	}
+}
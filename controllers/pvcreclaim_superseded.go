@@ -0,0 +1,97 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+// supersedingClaim returns the claimRef of a Bound PV when it points to a
+// claim other than the PVC of the PVCReclaim, or nil. A PVC under the name of
+// the PVCReclaim bound with a new UID is the PVC tracked by the PVCReclaim, so
+// only a UID which matches neither that PVC nor the recorded one supersedes.
+// pvc is nil when no PVC with the name of the PVCReclaim exists.
+func supersedingClaim(pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) *corev1.ObjectReference {
+	claimRef := pv.Spec.ClaimRef
+	if pv.Status.Phase != corev1.VolumeBound || claimRef == nil {
+		return nil
+	}
+	if claimRef.Namespace != pvcReclaim.Namespace || claimRef.Name != pvcReclaim.Name {
+		return claimRef
+	}
+	if claimRef.UID == "" || claimRef.UID == pvcReclaim.Status.ClaimUID || (pvc != nil && claimRef.UID == pvc.UID) {
+		return nil
+	}
+	return claimRef
+}
+
+// syncSuperseded raises the Superseded condition while the PV is bound to
+// another claim and clears it once it is not. A restore requested while the
+// PVCReclaim is superseded is refused unless it is forced or a dry run. It
+// reports whether the PVCReclaim is superseded.
+func (r *PVCReclaimController) syncSuperseded(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	claimRef := supersedingClaim(pvcReclaim, pv, pvc)
+	if claimRef == nil {
+		if meta.FindStatusCondition(pvcReclaim.Status.Conditions, v1alpha1.ConditionSuperseded) == nil {
+			return false, nil
+		}
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		meta.RemoveStatusCondition(&pvcReclaim.Status.Conditions, v1alpha1.ConditionSuperseded)
+		return false, r.client.Status().Patch(ctx, pvcReclaim, patch)
+	}
+
+	message := fmt.Sprintf("PV %s is bound to PVC %s/%s (UID %s)", pv.Name, claimRef.Namespace, claimRef.Name, claimRef.UID)
+	if pvcReclaim.Spec.Restore && !pvcReclaim.Spec.Force && !pvcReclaim.Spec.DryRun {
+		logger.Info("PV is bound to another claim, refusing restore", "pv", pv.Name, "claimRef", fmt.Sprintf("%s/%s", claimRef.Namespace, claimRef.Name), "PVCReclaim", fmt.Sprintf("%s/%s", pvcReclaim.Namespace, pvcReclaim.Name))
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Spec.Restore = false
+		if err := r.client.Patch(ctx, pvcReclaim, patch); err != nil {
+			return true, err
+		}
+		patch = client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.RecoverStatus = v1alpha1.RecoveryFailed
+		pvcReclaim.Status.Reason = fmt.Sprintf("%s, set force to take it back", message)
+		if err := r.client.Status().Patch(ctx, pvcReclaim, patch); err != nil {
+			return true, err
+		}
+	}
+
+	condition := meta.FindStatusCondition(pvcReclaim.Status.Conditions, v1alpha1.ConditionSuperseded)
+	if condition != nil && condition.Status == metav1.ConditionTrue && condition.Message == message {
+		return true, nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	meta.SetStatusCondition(&pvcReclaim.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionSuperseded,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: pvcReclaim.Generation,
+		Reason:             "BoundToAnotherClaim",
+		Message:            message,
+	})
+	return true, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPVCReclaimController_Reconcile_Superseded_RefusesRestore(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Restore = true
+	pv.Spec.ClaimRef = &corev1.ObjectReference{
+		Namespace: "other",
+		Name:      "other-pvc",
+		UID:       types.UID("other-pvc-uid"),
+	}
+	pv.Status.Phase = corev1.VolumeBound
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv).WithObjects(reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.False(t, updatedReclaim.Spec.Restore)
+	assert.Equal(t, v1alpha1.RecoveryFailed, updatedReclaim.Status.RecoverStatus)
+	condition := meta.FindStatusCondition(updatedReclaim.Status.Conditions, v1alpha1.ConditionSuperseded)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, "BoundToAnotherClaim", condition.Reason)
+		assert.Contains(t, condition.Message, "other/other-pvc")
+	}
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &corev1.PersistentVolumeClaim{})
+	assert.True(t, errors.IsNotFound(err))
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "other-pvc", updatedPV.Spec.ClaimRef.Name)
+}
+
+func TestPVCReclaimController_Reconcile_Superseded_ForcedRestore(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.Restore = true
+	pv.Spec.ClaimRef = &corev1.ObjectReference{
+		Namespace: "other",
+		Name:      "other-pvc",
+		UID:       types.UID("other-pvc-uid"),
+	}
+	pv.Status.Phase = corev1.VolumeBound
+	reclaim.Spec.Force = true
+	pv.Spec.StorageClassName = "standard"
+	pv.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, &corev1.PersistentVolumeClaim{}).WithObjects(newPreflightNamespace(), storageClass, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var pvc corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &pvc))
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.Equal(t, "default", updatedPV.Spec.ClaimRef.Namespace)
+	assert.Equal(t, "test-reclaim", updatedPV.Spec.ClaimRef.Name)
+	assert.Equal(t, pvc.UID, updatedPV.Spec.ClaimRef.UID)
+}
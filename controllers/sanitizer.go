@@ -0,0 +1,213 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PVC spec fields the Sanitizer can drop
+const (
+	SpecFieldDataSource                = "dataSource"
+	SpecFieldDataSourceRef             = "dataSourceRef"
+	SpecFieldSelector                  = "selector"
+	SpecFieldVolumeAttributesClassName = "volumeAttributesClassName"
+)
+
+// SanitizerRules configure what the Sanitizer strips. Label and annotation keys
+// are matched against the patterns with path.Match, so `*` matches any run of
+// characters other than `/`.
+type SanitizerRules struct {
+	// IncludeLabels keeps only the labels matching one of the patterns, all labels when empty
+	IncludeLabels []string
+	// ExcludeLabels drops the labels matching one of the patterns
+	ExcludeLabels []string
+	// IncludeAnnotations keeps only the annotations matching one of the patterns, all annotations when empty
+	IncludeAnnotations []string
+	// ExcludeAnnotations drops the annotations matching one of the patterns
+	ExcludeAnnotations []string
+	// DropSpecFields are the PVC spec fields which are cleared
+	DropSpecFields []string
+}
+
+// DefaultSanitizerRules drop the metadata set by the PV controller, the
+// scheduler, kubectl and GitOps tools as well as the keys of this controller,
+// and the spec fields which would make the restored PVC provision or select a
+// volume instead of binding to its PV
+func DefaultSanitizerRules() SanitizerRules {
+	return SanitizerRules{
+		ExcludeLabels: []string{
+			reclaimMetadataPrefix + "*",
+			"argocd.argoproj.io/*",
+			"*.toolkit.fluxcd.io/*",
+		},
+		ExcludeAnnotations: []string{
+			reclaimMetadataPrefix + "*",
+			"pv.kubernetes.io/*",
+			"volume.kubernetes.io/*",
+			"volume.beta.kubernetes.io/*",
+			"kubectl.kubernetes.io/last-applied-configuration",
+			"argocd.argoproj.io/*",
+			"*.toolkit.fluxcd.io/*",
+		},
+		DropSpecFields: []string{
+			SpecFieldDataSource,
+			SpecFieldDataSourceRef,
+			SpecFieldSelector,
+		},
+	}
+}
+
+// Sanitizer strips the labels, annotations and spec fields of a PVC which
+// should not carry over from the deleted PVC to the restored one
+type Sanitizer struct {
+	rules SanitizerRules
+}
+
+// DefaultSanitizer returns a Sanitizer applying DefaultSanitizerRules
+func DefaultSanitizer() *Sanitizer {
+	return &Sanitizer{rules: DefaultSanitizerRules()}
+}
+
+// NewSanitizer returns a Sanitizer applying the rules, or an error if a pattern
+// is malformed or a spec field cannot be dropped
+func NewSanitizer(rules SanitizerRules) (*Sanitizer, error) {
+	for _, patterns := range [][]string{rules.IncludeLabels, rules.ExcludeLabels, rules.IncludeAnnotations, rules.ExcludeAnnotations} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	for _, field := range rules.DropSpecFields {
+		switch field {
+		case SpecFieldDataSource, SpecFieldDataSourceRef, SpecFieldSelector, SpecFieldVolumeAttributesClassName:
+		default:
+			return nil, fmt.Errorf("unsupported spec field %q", field)
+		}
+	}
+	return &Sanitizer{rules: rules}, nil
+}
+
+// Labels returns a copy of the labels without the ones the rules strip
+func (s *Sanitizer) Labels(labels map[string]string) map[string]string {
+	return filterKeys(labels, s.rules.IncludeLabels, s.rules.ExcludeLabels)
+}
+
+// Annotations returns a copy of the annotations without the ones the rules strip
+func (s *Sanitizer) Annotations(annotations map[string]string) map[string]string {
+	return filterKeys(annotations, s.rules.IncludeAnnotations, s.rules.ExcludeAnnotations)
+}
+
+// ClaimSpec returns a copy of the PVC spec with the fields the rules drop cleared
+func (s *Sanitizer) ClaimSpec(spec *corev1.PersistentVolumeClaimSpec) corev1.PersistentVolumeClaimSpec {
+	sanitized := *spec.DeepCopy()
+	for _, field := range s.rules.DropSpecFields {
+		switch field {
+		case SpecFieldDataSource:
+			sanitized.DataSource = nil
+		case SpecFieldDataSourceRef:
+			sanitized.DataSourceRef = nil
+		case SpecFieldSelector:
+			sanitized.Selector = nil
+		case SpecFieldVolumeAttributesClassName:
+			sanitized.VolumeAttributesClassName = nil
+		}
+	}
+	return sanitized
+}
+
+// filterKeys copies the entries whose key matches one of the includes, if any,
+// and none of the excludes. A nil map stays nil.
+func filterKeys(entries map[string]string, includes, excludes []string) map[string]string {
+	if entries == nil {
+		return nil
+	}
+	filtered := make(map[string]string, len(entries))
+	for key, value := range entries {
+		if len(includes) > 0 && !matchesAny(key, includes) {
+			continue
+		}
+		if matchesAny(key, excludes) {
+			continue
+		}
+		filtered[key] = value
+	}
+	return filtered
+}
+
+// matchesAny reports whether the key matches one of the patterns
+func matchesAny(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// PersistentVolumeAnnotationPolicy decides which annotations a restore removes
+// from the PV. An annotation whose key starts with one of the Deny prefixes is
+// removed unless a longer Allow prefix matches it too, so a narrow allow can
+// carve an exception out of a broad deny and the other way around.
+type PersistentVolumeAnnotationPolicy struct {
+	// Allow are the key prefixes of annotations preserved on restore
+	Allow []string
+	// Deny are the key prefixes of annotations removed on restore
+	Deny []string
+}
+
+// DefaultPersistentVolumeAnnotationPolicy only removes the annotation recording
+// that the PV controller bound the PV, which no longer holds for the restored PVC
+func DefaultPersistentVolumeAnnotationPolicy() PersistentVolumeAnnotationPolicy {
+	return PersistentVolumeAnnotationPolicy{
+		Deny: []string{
+			"pv.kubernetes.io/bound-by-controller",
+		},
+	}
+}
+
+// Removed returns the annotations the policy removes from the PV, nil if none
+func (p PersistentVolumeAnnotationPolicy) Removed(annotations map[string]string) map[string]string {
+	var removed map[string]string
+	for key, value := range annotations {
+		deny := longestPrefix(key, p.Deny)
+		if deny == 0 || longestPrefix(key, p.Allow) >= deny {
+			continue
+		}
+		if removed == nil {
+			removed = make(map[string]string)
+		}
+		removed[key] = value
+	}
+	return removed
+}
+
+// longestPrefix returns the length of the longest prefix the key starts with, 0 if none
+func longestPrefix(key string, prefixes []string) int {
+	longest := 0
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(key, prefix) && len(prefix) > longest {
+			longest = len(prefix)
+		}
+	}
+	return longest
+}
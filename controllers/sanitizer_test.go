@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSanitizer_Default(t *testing.T) {
+	sanitizer := DefaultSanitizer()
+
+	labels := sanitizer.Labels(map[string]string{
+		"app":                              "db",
+		reclaimPVLabel:                     "test-pv",
+		"argocd.argoproj.io/instance":      "db",
+		"kustomize.toolkit.fluxcd.io/name": "apps",
+	})
+	assert.Equal(t, map[string]string{"app": "db"}, labels)
+
+	annotations := sanitizer.Annotations(map[string]string{
+		"owner":                                            "team-a",
+		"pv.kubernetes.io/bind-completed":                  "yes",
+		"volume.kubernetes.io/selected-node":               "node-1",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		confirmDeleteAnnotation:                            "true",
+	})
+	assert.Equal(t, map[string]string{"owner": "team-a"}, annotations)
+	assert.Nil(t, sanitizer.Annotations(nil))
+
+	apiGroup := "snapshot.storage.k8s.io"
+	spec := &corev1.PersistentVolumeClaimSpec{
+		VolumeName:    "test-pv",
+		DataSource:    &corev1.TypedLocalObjectReference{APIGroup: &apiGroup, Kind: "VolumeSnapshot", Name: "snap"},
+		DataSourceRef: &corev1.TypedObjectReference{APIGroup: &apiGroup, Kind: "VolumeSnapshot", Name: "snap"},
+		Selector:      &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}},
+	}
+	sanitized := sanitizer.ClaimSpec(spec)
+	assert.Equal(t, "test-pv", sanitized.VolumeName)
+	assert.Nil(t, sanitized.DataSource)
+	assert.Nil(t, sanitized.DataSourceRef)
+	assert.Nil(t, sanitized.Selector)
+	assert.NotNil(t, spec.DataSource)
+}
+
+func TestSanitizer_IncludeRules(t *testing.T) {
+	sanitizer, err := NewSanitizer(SanitizerRules{
+		IncludeLabels: []string{"app", "app.kubernetes.io/*"},
+		ExcludeLabels: []string{"app.kubernetes.io/instance"},
+	})
+	assert.NoError(t, err)
+
+	labels := sanitizer.Labels(map[string]string{
+		"app":                        "db",
+		"app.kubernetes.io/name":     "postgres",
+		"app.kubernetes.io/instance": "db-prod",
+		"team":                       "a",
+	})
+	assert.Equal(t, map[string]string{"app": "db", "app.kubernetes.io/name": "postgres"}, labels)
+	assert.Equal(t, map[string]string{"team": "a"}, sanitizer.Annotations(map[string]string{"team": "a"}))
+}
+
+func TestNewSanitizer_Invalid(t *testing.T) {
+	_, err := NewSanitizer(SanitizerRules{ExcludeLabels: []string{"["}})
+	assert.Error(t, err)
+
+	_, err = NewSanitizer(SanitizerRules{DropSpecFields: []string{"volumeName"}})
+	assert.Error(t, err)
+
+	_, err = NewSanitizer(DefaultSanitizerRules())
+	assert.NoError(t, err)
+}
+
+func TestPVCReclaimController_Reconcile_Restore_Sanitized(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	reclaim.Labels["app"] = "db"
+	reclaim.Labels["argocd.argoproj.io/instance"] = "db"
+	reclaim.Annotations = map[string]string{
+		"owner":                           "team-a",
+		"pv.kubernetes.io/bind-completed": "yes",
+	}
+	reclaim.Spec.PersistentVolumeClaimSpec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}}
+	storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, &corev1.PersistentVolumeClaim{}).WithObjects(newPreflightNamespace(), storageClass, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var pvc corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &pvc))
+	assert.Equal(t, map[string]string{"app": "db"}, pvc.Labels)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, pvc.Annotations)
+	assert.Nil(t, pvc.Spec.Selector)
+	assert.Equal(t, "test-pv", pvc.Spec.VolumeName)
+}
+
+func TestPVCController_Reconcile_CaptureSanitized(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pvc",
+			Namespace: "default",
+			Labels: map[string]string{
+				"app":                         "db",
+				"argocd.argoproj.io/instance": "db",
+			},
+			Annotations: map[string]string{
+				"owner":                           "team-a",
+				"pv.kubernetes.io/bind-completed": "yes",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: "test-pv",
+			Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&v1alpha1.PVCReclaim{}).WithObjects(pvc, pv).Build()
+	sanitizer, err := NewSanitizer(SanitizerRules{
+		ExcludeLabels:      []string{"argocd.argoproj.io/*"},
+		ExcludeAnnotations: []string{"pv.kubernetes.io/*"},
+	})
+	assert.NoError(t, err)
+	controller := NewPVCController(fakeClient, WithClaimSanitizer(sanitizer))
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-pvc", Namespace: "default"}}
+	_, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var reclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &reclaim))
+	assert.Equal(t, map[string]string{"app": "db", reclaimPVLabel: "test-pv"}, reclaim.Labels)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, reclaim.Annotations)
+	// the selector is only dropped by the default rules
+	assert.NotNil(t, reclaim.Spec.PersistentVolumeClaimSpec.Selector)
+}
+
+func TestPersistentVolumeAnnotationPolicy_Removed(t *testing.T) {
+	annotations := map[string]string{
+		"pv.kubernetes.io/bound-by-controller": "yes",
+		"pv.kubernetes.io/provisioned-by":      "test.csi.yibozhuang.me",
+		"pv.kubernetes.io/migrated-to":         "test.csi.yibozhuang.me",
+		claimRecordAnnotation:                  "v1.record",
+	}
+
+	removed := DefaultPersistentVolumeAnnotationPolicy().Removed(annotations)
+	assert.Equal(t, map[string]string{"pv.kubernetes.io/bound-by-controller": "yes"}, removed)
+
+	policy := PersistentVolumeAnnotationPolicy{
+		Allow: []string{"pv.kubernetes.io/migrated-to"},
+		Deny:  []string{"pv.kubernetes.io/", reclaimMetadataPrefix},
+	}
+	removed = policy.Removed(annotations)
+	assert.Equal(t, map[string]string{
+		"pv.kubernetes.io/bound-by-controller": "yes",
+		"pv.kubernetes.io/provisioned-by":      "test.csi.yibozhuang.me",
+		claimRecordAnnotation:                  "v1.record",
+	}, removed)
+
+	assert.Nil(t, PersistentVolumeAnnotationPolicy{}.Removed(annotations))
+}
+
+func TestPVCReclaimController_Reconcile_Restore_RemovedPVAnnotations(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+
+	storageClassName := "standard"
+	reclaim, pv := newReleasedFixtures()
+	reclaim.Spec.PersistentVolumeClaimSpec.StorageClassName = &storageClassName
+	reclaim.Spec.Restore = true
+	reclaim.Spec.LegalHold = &v1alpha1.LegalHold{Enabled: true, RequestedBy: "legal"}
+	pv.Annotations = map[string]string{
+		"pv.kubernetes.io/bound-by-controller": "yes",
+		"pv.kubernetes.io/migrated-to":         "test.csi.yibozhuang.me",
+		"backup.example.com/schedule":          "daily",
+	}
+	storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(reclaim, pv, &corev1.PersistentVolumeClaim{}).WithObjects(newPreflightNamespace(), storageClass, reclaim, pv).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPV corev1.PersistentVolume
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-pv"}, &updatedPV))
+	assert.NotContains(t, updatedPV.Annotations, "pv.kubernetes.io/bound-by-controller")
+	assert.Equal(t, "test.csi.yibozhuang.me", updatedPV.Annotations["pv.kubernetes.io/migrated-to"])
+	assert.Equal(t, "daily", updatedPV.Annotations["backup.example.com/schedule"])
+
+	// the legal hold keeps the PVCReclaim after the restore
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, v1alpha1.RecoverySuccess, updatedReclaim.Status.RecoverStatus)
+	assert.Equal(t, map[string]string{"pv.kubernetes.io/bound-by-controller": "yes"}, updatedReclaim.Status.RemovedPersistentVolumeAnnotations)
+}
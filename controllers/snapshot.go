@@ -0,0 +1,259 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+)
+
+const (
+	// snapshotClassAnnotation on a StorageClass names the VolumeSnapshotClass
+	// used to snapshot its PVCs when they are deleted
+	snapshotClassAnnotation = "pvc-reclaim.yibozhuang.me/snapshot-class"
+	// snapshotFinalizer holds back the release of a PVC until it has been snapshotted
+	snapshotFinalizer = "pvc-reclaim.yibozhuang.me/snapshot"
+	// snapshotPollInterval is how often a deleted PVC checks whether its snapshot has been cut
+	snapshotPollInterval = 5 * time.Second
+	// snapshotCutTimeout is how long the release of a PVC waits for its snapshot to be cut
+	snapshotCutTimeout = 10 * time.Minute
+)
+
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshotcontents,verbs=get;list;watch;create;delete
+
+// snapshotName returns the name of the VolumeSnapshot and VolumeSnapshotContent taken of the PVC
+func snapshotName(pvc *corev1.PersistentVolumeClaim) string {
+	return fmt.Sprintf("pvc-reclaim-%s", pvc.UID)
+}
+
+// snapshotClassName returns the VolumeSnapshotClass the StorageClass of the
+// PVC asks its PVCs to be snapshotted with on release, or "" if it does not
+func (r *PVCController) snapshotClassName(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return "", nil
+	}
+	var storageClass storagev1.StorageClass
+	if err := r.client.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, &storageClass); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	return storageClass.Annotations[snapshotClassAnnotation], nil
+}
+
+// ensureSnapshotFinalizer adds the snapshot finalizer to a Bound PVC of a CSI
+// PV whose StorageClass asks for a snapshot on release
+func (r *PVCController) ensureSnapshotFinalizer(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) error {
+	if pv.Spec.CSI == nil || controllerutil.ContainsFinalizer(pvc, snapshotFinalizer) {
+		return nil
+	}
+	className, err := r.snapshotClassName(ctx, pvc)
+	if err != nil || className == "" {
+		return err
+	}
+	patch := client.MergeFrom(pvc.DeepCopy())
+	controllerutil.AddFinalizer(pvc, snapshotFinalizer)
+	return r.client.Patch(ctx, pvc, patch)
+}
+
+// snapshotOnRelease snapshots a deleted PVC before it lets the PVC go. The
+// snapshot is taken from the CSI volume handle through a VolumeSnapshotContent,
+// since the snapshot controller refuses PVCs being deleted as a source, and is
+// owned by the PVCReclaim so it lives as long as the PVCReclaim does. The
+// release waits until the snapshot is cut, fails or takes too long.
+func (r *PVCController) snapshotOnRelease(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var pvcReclaim v1alpha1.PVCReclaim
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, &pvcReclaim); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.removeSnapshotFinalizer(ctx, pvc)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if pvcReclaim.Status.Snapshot == nil || pvcReclaim.Status.Snapshot.Name != snapshotName(pvc) {
+		created, err := r.createSnapshot(ctx, pvc, &pvcReclaim)
+		if err != nil || !created {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var snapshot snapshotv1.VolumeSnapshot
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: pvcReclaim.Status.Snapshot.Name}, &snapshot); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.removeSnapshotFinalizer(ctx, pvc)
+		}
+		return ctrl.Result{}, err
+	}
+	if err := recordSnapshotStatus(ctx, r.client, &pvcReclaim, &snapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := snapshot.Status
+	cut := status != nil && (status.CreationTime != nil || status.Error != nil)
+	if !cut && time.Since(pvc.DeletionTimestamp.Time) < snapshotCutTimeout {
+		return ctrl.Result{RequeueAfter: snapshotPollInterval}, nil
+	}
+	if !cut {
+		logger.Info("Timed out waiting for snapshot to be cut, releasing PVC", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "snapshot", snapshot.Name)
+		patch := client.MergeFrom(pvcReclaim.DeepCopy())
+		pvcReclaim.Status.Snapshot.Error = fmt.Sprintf("Snapshot was not cut within %s of the PVC deletion", snapshotCutTimeout)
+		if err := r.client.Status().Patch(ctx, &pvcReclaim, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.removeSnapshotFinalizer(ctx, pvc)
+}
+
+// createSnapshot creates the VolumeSnapshotContent and VolumeSnapshot of the
+// PVC and records them on the PVCReclaim. It reports false when the PVC can no
+// longer be snapshotted, in which case its finalizer has been removed.
+func (r *PVCController) createSnapshot(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pvcReclaim *v1alpha1.PVCReclaim) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	className, err := r.snapshotClassName(ctx, pvc)
+	if err != nil {
+		return false, err
+	}
+	var pv corev1.PersistentVolume
+	if err := r.client.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	if className == "" || pv.Spec.CSI == nil {
+		return false, r.removeSnapshotFinalizer(ctx, pvc)
+	}
+
+	name := snapshotName(pvc)
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			VolumeSnapshotRef: corev1.ObjectReference{
+				Kind:       "VolumeSnapshot",
+				APIVersion: snapshotv1.SchemeGroupVersion.String(),
+				Namespace:  pvc.Namespace,
+				Name:       name,
+			},
+			DeletionPolicy:          snapshotv1.VolumeSnapshotContentDelete,
+			Driver:                  pv.Spec.CSI.Driver,
+			VolumeSnapshotClassName: &className,
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				VolumeHandle: &pv.Spec.CSI.VolumeHandle,
+			},
+		},
+	}
+	logger.Info("Snapshotting PVC before release", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name), "snapshot", name, "volumeSnapshotClass", className)
+	if err := r.client.Create(ctx, content); err != nil && !errors.IsAlreadyExists(err) {
+		if snapshotsUnavailable(err) {
+			logger.Info("VolumeSnapshots are not served by the cluster, releasing PVC without a snapshot", "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
+			return false, r.removeSnapshotFinalizer(ctx, pvc)
+		}
+		return false, err
+	}
+
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pvc.Namespace,
+			Labels: map[string]string{
+				reclaimPVLabel: pv.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(pvcReclaim, v1alpha1.GroupVersion.WithKind("PVCReclaim")),
+			},
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{
+				VolumeSnapshotContentName: &name,
+			},
+			VolumeSnapshotClassName: &className,
+		},
+	}
+	if err := r.client.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Snapshot = &v1alpha1.SnapshotReference{
+		Name:                    name,
+		ContentName:             name,
+		VolumeSnapshotClassName: className,
+	}
+	return true, r.client.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// recordSnapshotStatus mirrors the status of the VolumeSnapshot into the snapshot reference of the PVCReclaim
+func recordSnapshotStatus(ctx context.Context, c client.Client, pvcReclaim *v1alpha1.PVCReclaim, snapshot *snapshotv1.VolumeSnapshot) error {
+	if snapshot.Status == nil {
+		return nil
+	}
+	reference := pvcReclaim.Status.Snapshot.DeepCopy()
+	reference.CreationTime = snapshot.Status.CreationTime
+	reference.ReadyToUse = snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+	reference.RestoreSize = snapshot.Status.RestoreSize
+	reference.Error = ""
+	if snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+		reference.Error = *snapshot.Status.Error.Message
+	}
+	if equality.Semantic.DeepEqual(reference, pvcReclaim.Status.Snapshot) {
+		return nil
+	}
+	patch := client.MergeFrom(pvcReclaim.DeepCopy())
+	pvcReclaim.Status.Snapshot = reference
+	return c.Status().Patch(ctx, pvcReclaim, patch)
+}
+
+// syncSnapshotStatus keeps the snapshot reference of the PVCReclaim up to date
+// with its VolumeSnapshot, which is cut and becomes ready after the PVC is gone
+func (r *PVCReclaimController) syncSnapshotStatus(ctx context.Context, pvcReclaim *v1alpha1.PVCReclaim) error {
+	if pvcReclaim.Status.Snapshot == nil {
+		return nil
+	}
+	var snapshot snapshotv1.VolumeSnapshot
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: pvcReclaim.Namespace, Name: pvcReclaim.Status.Snapshot.Name}, &snapshot); err != nil {
+		if errors.IsNotFound(err) || snapshotsUnavailable(err) {
+			return nil
+		}
+		return err
+	}
+	return recordSnapshotStatus(ctx, r.client, pvcReclaim, &snapshot)
+}
+
+// removeSnapshotFinalizer lets the deleted PVC be released
+func (r *PVCController) removeSnapshotFinalizer(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	if !controllerutil.RemoveFinalizer(pvc, snapshotFinalizer) {
+		return nil
+	}
+	return r.client.Patch(ctx, pvc, patch)
+}
@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/yibozhuang/pvc-reclaim/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newSnapshotScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+	_ = storagev1.AddToScheme(s)
+	_ = snapshotv1.AddToScheme(s)
+	return s
+}
+
+func TestPVCController_Reconcile_AddsSnapshotFinalizer(t *testing.T) {
+	storageClassName := "csi"
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pvc",
+			Namespace: "default",
+			UID:       types.UID("pvc-uid"),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName:       "test-pv",
+			StorageClassName: &storageClassName,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName:              storageClassName,
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"},
+			},
+		},
+	}
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        storageClassName,
+			Annotations: map[string]string{snapshotClassAnnotation: "csi-snapclass"},
+		},
+		Provisioner: "test.csi.yibozhuang.me",
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(&v1alpha1.PVCReclaim{}).WithObjects(pvc, pv, storageClass).Build()
+	controller := NewPVCController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-pvc", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPVC corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC))
+	assert.Contains(t, updatedPVC.Finalizers, snapshotFinalizer)
+}
+
+func TestPVCController_Reconcile_NoSnapshotFinalizerWithoutSnapshotClass(t *testing.T) {
+	// a StorageClass without the annotation does not opt in
+	storageClassName := "csi"
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pvc",
+			Namespace: "default",
+			UID:       types.UID("pvc-uid"),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName:       "test-pv",
+			StorageClassName: &storageClassName,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName:              storageClassName,
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"},
+			},
+		},
+	}
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: storageClassName,
+		},
+		Provisioner: "test.csi.yibozhuang.me",
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(&v1alpha1.PVCReclaim{}).WithObjects(pvc, pv, storageClass).Build()
+	controller := NewPVCController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-pvc", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPVC corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC))
+	assert.NotContains(t, updatedPVC.Finalizers, snapshotFinalizer)
+}
+
+func TestPVCController_Reconcile_SnapshotOnRelease(t *testing.T) {
+	storageClassName := "csi"
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-pvc",
+			Namespace:         "default",
+			UID:               types.UID("pvc-uid"),
+			Finalizers:        []string{snapshotFinalizer},
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName:       "test-pv",
+			StorageClassName: &storageClassName,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName:              storageClassName,
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"},
+			},
+		},
+	}
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        storageClassName,
+			Annotations: map[string]string{snapshotClassAnnotation: "csi-snapclass"},
+		},
+		Provisioner: "test.csi.yibozhuang.me",
+	}
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pvc",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.PVCReclaimSpec{
+			PersistentVolumeRef: &corev1.ObjectReference{Name: "test-pv"},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim).WithObjects(pvc, pv, storageClass, reclaim).Build()
+	controller := NewPVCController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-pvc", Namespace: "default"}}
+	result, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotPollInterval, result.RequeueAfter)
+
+	var content snapshotv1.VolumeSnapshotContent
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "pvc-reclaim-pvc-uid"}, &content))
+	assert.Equal(t, "vol-1234", *content.Spec.Source.VolumeHandle)
+	assert.Equal(t, "csi-snapclass", *content.Spec.VolumeSnapshotClassName)
+	assert.Equal(t, "default", content.Spec.VolumeSnapshotRef.Namespace)
+
+	var snapshot snapshotv1.VolumeSnapshot
+	assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "pvc-reclaim-pvc-uid", Namespace: "default"}, &snapshot))
+	assert.Equal(t, "pvc-reclaim-pvc-uid", *snapshot.Spec.Source.VolumeSnapshotContentName)
+	assert.Equal(t, "PVCReclaim", snapshot.OwnerReferences[0].Kind)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Equal(t, "pvc-reclaim-pvc-uid", updatedReclaim.Status.Snapshot.Name)
+	assert.Equal(t, "csi-snapclass", updatedReclaim.Status.Snapshot.VolumeSnapshotClassName)
+
+	// the PVC is held back until the snapshot is cut
+	var updatedPVC corev1.PersistentVolumeClaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC))
+
+	restoreSize := resource.MustParse("10Gi")
+	snapshot.Status = &snapshotv1.VolumeSnapshotStatus{
+		CreationTime: &metav1.Time{Time: time.Now()},
+		ReadyToUse:   &[]bool{true}[0],
+		RestoreSize:  &restoreSize,
+	}
+	assert.NoError(t, fakeClient.Update(context.Background(), &snapshot))
+
+	result, err = controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC)
+	assert.True(t, errors.IsNotFound(err))
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, updatedReclaim.Status.Snapshot.ReadyToUse)
+	assert.Equal(t, "10Gi", updatedReclaim.Status.Snapshot.RestoreSize.String())
+}
+
+func TestPVCController_Reconcile_SnapshotOnRelease_Timeout(t *testing.T) {
+	storageClassName := "csi"
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-pvc",
+			Namespace:         "default",
+			UID:               types.UID("pvc-uid"),
+			Finalizers:        []string{snapshotFinalizer},
+			DeletionTimestamp: &metav1.Time{Time: time.Now().Add(-snapshotCutTimeout)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName:       "test-pv",
+			StorageClassName: &storageClassName,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName:              storageClassName,
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"},
+			},
+		},
+	}
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        storageClassName,
+			Annotations: map[string]string{snapshotClassAnnotation: "csi-snapclass"},
+		},
+		Provisioner: "test.csi.yibozhuang.me",
+	}
+	reclaim := &v1alpha1.PVCReclaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pvc",
+			Namespace: "default",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim).WithObjects(pvc, pv, storageClass, reclaim).Build()
+	controller := NewPVCController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-pvc", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedPVC corev1.PersistentVolumeClaim
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &updatedPVC)
+	assert.True(t, errors.IsNotFound(err))
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.Contains(t, updatedReclaim.Status.Snapshot.Error, "was not cut")
+}
+
+func TestPVCReclaimController_Reconcile_MissingPersistentVolume_SnapshotRetained(t *testing.T) {
+	reclaim, pv := newReleasedFixtures()
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "test.csi.yibozhuang.me", VolumeHandle: "vol-1234"}
+	reclaim.Spec.PersistentVolumeSpec = sanitizedPersistentVolumeSpec(pv)
+	reclaim.Status.Snapshot = &v1alpha1.SnapshotReference{Name: "pvc-reclaim-old-pvc-uid", ContentName: "pvc-reclaim-old-pvc-uid"}
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc-reclaim-old-pvc-uid",
+			Namespace: "default",
+		},
+		Status: &snapshotv1.VolumeSnapshotStatus{
+			CreationTime: &metav1.Time{Time: time.Now()},
+			ReadyToUse:   &[]bool{true}[0],
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(newSnapshotScheme()).WithStatusSubresource(reclaim).WithObjects(reclaim, snapshot).Build()
+	controller := NewPVCReclaimController(fakeClient)
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-reclaim", Namespace: "default"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.NoError(t, err)
+
+	var updatedReclaim v1alpha1.PVCReclaim
+	assert.NoError(t, fakeClient.Get(context.Background(), req.NamespacedName, &updatedReclaim))
+	assert.True(t, meta.IsStatusConditionTrue(updatedReclaim.Status.Conditions, v1alpha1.ConditionPersistentVolumeMissing))
+	assert.Contains(t, updatedReclaim.Status.Message, "VolumeSnapshot pvc-reclaim-old-pvc-uid")
+	assert.True(t, updatedReclaim.Status.Snapshot.ReadyToUse)
+}
@@ -19,12 +19,17 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -41,17 +46,75 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(snapshotv1.AddToScheme(scheme))
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var csiControllerEndpoint string
+	var adoptionInterval time.Duration
+	var driftSweepInterval time.Duration
+	var sanitizeIncludeLabels, sanitizeExcludeLabels string
+	var sanitizeIncludeAnnotations, sanitizeExcludeAnnotations string
+	var sanitizeDropSpecFields string
+	var pvAnnotationAllowPrefixes, pvAnnotationDenyPrefixes string
+	var migrationImage, migrationStagingNamespace string
+	var previewImage string
+	var previewTimeout time.Duration
+	var contentManifest, contentManifestChecksums bool
+	var contentManifestImage string
+	var archiveImage string
+	defaultRules := controllers.DefaultSanitizerRules()
+	defaultPVAnnotationPolicy := controllers.DefaultPersistentVolumeAnnotationPolicy()
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&csiControllerEndpoint, "csi-controller-endpoint", "",
+		"The CSI controller socket used to delete backing volumes when a PVCReclaim is purged. "+
+			"If unset, purged PVs have their reclaim policy switched to Delete instead.")
+	flag.DurationVar(&adoptionInterval, "adoption-interval", 10*time.Minute,
+		"How often Released PVs without a PVCReclaim are swept for adoption. "+
+			"If 0, the sweep only runs on startup.")
+	flag.DurationVar(&driftSweepInterval, "drift-sweep-interval", 5*time.Minute,
+		"How often PVs, PVCs and PVCReclaims are cross-checked for drift. "+
+			"If 0, the drift sweep is disabled.")
+	flag.StringVar(&sanitizeIncludeLabels, "sanitize-include-labels", strings.Join(defaultRules.IncludeLabels, ","),
+		"Comma separated patterns of the PVC labels kept on capture and restore. If empty, all labels are kept.")
+	flag.StringVar(&sanitizeExcludeLabels, "sanitize-exclude-labels", strings.Join(defaultRules.ExcludeLabels, ","),
+		"Comma separated patterns of the PVC labels dropped on capture and restore.")
+	flag.StringVar(&sanitizeIncludeAnnotations, "sanitize-include-annotations", strings.Join(defaultRules.IncludeAnnotations, ","),
+		"Comma separated patterns of the PVC annotations kept on capture and restore. If empty, all annotations are kept.")
+	flag.StringVar(&sanitizeExcludeAnnotations, "sanitize-exclude-annotations", strings.Join(defaultRules.ExcludeAnnotations, ","),
+		"Comma separated patterns of the PVC annotations dropped on capture and restore.")
+	flag.StringVar(&sanitizeDropSpecFields, "sanitize-drop-spec-fields", strings.Join(defaultRules.DropSpecFields, ","),
+		"Comma separated PVC spec fields cleared on capture and restore, "+
+			"out of dataSource, dataSourceRef, selector and volumeAttributesClassName.")
+	flag.StringVar(&pvAnnotationAllowPrefixes, "pv-annotation-allow-prefixes", strings.Join(defaultPVAnnotationPolicy.Allow, ","),
+		"Comma separated key prefixes of the PV annotations preserved on restore. "+
+			"The longest matching allow or deny prefix decides.")
+	flag.StringVar(&pvAnnotationDenyPrefixes, "pv-annotation-deny-prefixes", strings.Join(defaultPVAnnotationPolicy.Deny, ","),
+		"Comma separated key prefixes of the PV annotations removed on restore.")
+	flag.StringVar(&migrationImage, "migration-image", controllers.DefaultMigrationImage,
+		"The image with rsync run by the Job copying data when a PVC is restored into another StorageClass.")
+	flag.StringVar(&migrationStagingNamespace, "migration-staging-namespace", controllers.DefaultMigrationStagingNamespace,
+		"The namespace the data is copied in when a PVC is restored into another StorageClass, "+
+			"unless the PVCReclaim names a staging namespace.")
+	flag.StringVar(&previewImage, "preview-image", controllers.DefaultPreviewImage,
+		"The image of the inspection pod mounting a previewed PV read-only.")
+	flag.DurationVar(&previewTimeout, "preview-timeout", controllers.DefaultPreviewTimeout,
+		"How long a preview runs before the inspection pod is deleted and the PV is Released again.")
+	flag.BoolVar(&contentManifest, "content-manifest", false,
+		"Scan the content of PVs once they are Released, recording a manifest into a ConfigMap next to the PVCReclaim.")
+	flag.BoolVar(&contentManifestChecksums, "content-manifest-checksums", false,
+		"Add a sha256 checksum of every file to the content manifest.")
+	flag.StringVar(&contentManifestImage, "content-manifest-image", controllers.DefaultContentManifestImage,
+		"The image of the Job scanning the content of a released PV.")
+	flag.StringVar(&archiveImage, "archive-image", controllers.DefaultArchiveImage,
+		"The image with rclone of the Jobs uploading PVs to object storage and extracting them into restored PVCs.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -60,6 +123,18 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	sanitizer, err := controllers.NewSanitizer(controllers.SanitizerRules{
+		IncludeLabels:      splitList(sanitizeIncludeLabels),
+		ExcludeLabels:      splitList(sanitizeExcludeLabels),
+		IncludeAnnotations: splitList(sanitizeIncludeAnnotations),
+		ExcludeAnnotations: splitList(sanitizeExcludeAnnotations),
+		DropSpecFields:     splitList(sanitizeDropSpecFields),
+	})
+	if err != nil {
+		setupLog.Error(err, "invalid sanitizer rules")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
@@ -74,14 +149,50 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = controllers.NewPVCReclaimController(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+	pvAnnotationPolicy := controllers.PersistentVolumeAnnotationPolicy{
+		Allow: splitList(pvAnnotationAllowPrefixes),
+		Deny:  splitList(pvAnnotationDenyPrefixes),
+	}
+	resync := make(chan event.GenericEvent)
+	reclaimOpts := []controllers.PVCReclaimControllerOption{
+		controllers.WithCSIControllerEndpoint(csiControllerEndpoint), controllers.WithResyncEvents(resync), controllers.WithSanitizer(sanitizer),
+		controllers.WithPersistentVolumeAnnotationPolicy(pvAnnotationPolicy), controllers.WithMigrationImage(migrationImage),
+		controllers.WithMigrationStagingNamespace(migrationStagingNamespace), controllers.WithPreviewImage(previewImage),
+		controllers.WithPreviewTimeout(previewTimeout), controllers.WithArchiveImage(archiveImage),
+	}
+	if contentManifest {
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "unable to create clientset")
+			os.Exit(1)
+		}
+		reclaimOpts = append(reclaimOpts, controllers.WithContentManifest(controllers.NewPodLogReader(clientset)),
+			controllers.WithContentManifestImage(contentManifestImage), controllers.WithContentManifestChecksums(contentManifestChecksums))
+	}
+	if err = controllers.NewPVCReclaimController(mgr.GetClient(), reclaimOpts...).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PVCReclaimController")
 		os.Exit(1)
 	}
-	if err = controllers.NewPVCController(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+	if err = controllers.NewPVCController(mgr.GetClient(), controllers.WithClaimSanitizer(sanitizer)).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PVCController")
 		os.Exit(1)
 	}
+	if err = controllers.NewPVCReclaimPopulator(mgr.GetClient(), controllers.WithPopulatorAnnotationPolicy(pvAnnotationPolicy)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PVCReclaimPopulator")
+		os.Exit(1)
+	}
+	if err = controllers.NewClusterPVCReclaimController(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterPVCReclaimController")
+		os.Exit(1)
+	}
+	if err = controllers.NewPVCReclaimAdopter(mgr.GetClient(), controllers.WithAdoptionInterval(adoptionInterval)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up adopter", "runnable", "PVCReclaimAdopter")
+		os.Exit(1)
+	}
+	if err = controllers.NewDriftSweeper(mgr.GetClient(), resync, controllers.WithDriftSweepInterval(driftSweepInterval)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up drift sweeper", "runnable", "DriftSweeper")
+		os.Exit(1)
+	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -98,3 +209,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag value, an empty value is an empty list
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}